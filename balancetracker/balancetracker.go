@@ -0,0 +1,189 @@
+package balancetracker
+
+import (
+	"fmt"
+
+	"github.com/brunoamancio/NotSolo/keypairmanager"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// BalanceTracker records balances in L1 and in chains, so that their changes can be verified later
+type BalanceTracker struct {
+	env             *solo.Solo
+	keyPairManager  *keypairmanager.KeyPairManager
+	l1Balances      map[string]colored.Balances
+	accountBalances map[*solo.Chain]map[string]colored.Balances
+}
+
+// New instantiates a balance tracker and records the current balances of 'targets'. Targets may be:
+//
+//	*ed25519.KeyPair: its balances in L1 and in every tracked chain are tracked
+//	*iscp.AgentID: its balances in every tracked chain are tracked
+//	*solo.Chain: the balances of the chain in L1 and of its own account are tracked
+//	string: the balances of the contract with this name in every tracked chain are tracked
+//
+//	Fails test if a target is of another type or a contract is not found in any tracked chain.
+func New(env *solo.Solo, keyPairManager *keypairmanager.KeyPairManager, targets ...interface{}) *BalanceTracker {
+	balanceTracker := &BalanceTracker{
+		env:             env,
+		keyPairManager:  keyPairManager,
+		l1Balances:      make(map[string]colored.Balances),
+		accountBalances: make(map[*solo.Chain]map[string]colored.Balances),
+	}
+
+	var addresses []ledgerstate.Address
+	var agentIDs []*iscp.AgentID
+	var contractNames []string
+	for _, target := range targets {
+		switch target := target.(type) {
+		case *ed25519.KeyPair:
+			address := ledgerstate.NewED25519Address(target.PublicKey)
+			addresses = append(addresses, address)
+			agentIDs = append(agentIDs, iscp.NewAgentID(address, 0))
+		case *iscp.AgentID:
+			agentIDs = append(agentIDs, target)
+		case *solo.Chain:
+			addresses = append(addresses, target.ChainID.AsAddress())
+			balanceTracker.accountBalances[target] = make(map[string]colored.Balances)
+		case string:
+			contractNames = append(contractNames, target)
+		default:
+			require.FailNow(env.T, fmt.Sprintf("Balances of %T cannot be tracked", target))
+		}
+	}
+
+	for _, address := range addresses {
+		balanceTracker.l1Balances[address.Base58()] = env.GetAddressBalances(address)
+	}
+
+	for chain, accountBalances := range balanceTracker.accountBalances {
+		accountBalances[iscp.NewAgentID(chain.ChainID.AsAddress(), 0).String()] = getAccountBalances(chain, iscp.NewAgentID(chain.ChainID.AsAddress(), 0))
+		for _, agentID := range agentIDs {
+			accountBalances[agentID.String()] = getAccountBalances(chain, agentID)
+		}
+	}
+
+	for _, contractName := range contractNames {
+		isContractFound := false
+		for chain, accountBalances := range balanceTracker.accountBalances {
+			if contractRecord, err := chain.FindContract(contractName); err == nil && contractRecord != nil {
+				contractAgentID := chain.ContractAgentID(contractName)
+				accountBalances[contractAgentID.String()] = getAccountBalances(chain, contractAgentID)
+				isContractFound = true
+			}
+		}
+		require.True(env.T, isContractFound, "Contract %s could not be found in any tracked chain", contractName)
+	}
+
+	return balanceTracker
+}
+
+// RequireDelta verifies if the balance of 'color' of the key pair, summed over L1 and its accounts in every tracked chain, changed by
+// 'expectedDelta' since tracking started. Fails test if the key pair is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireDelta(keyPair *ed25519.KeyPair, color colored.Color, expectedDelta int64) {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	delta := balanceTracker.GetDelta(keyPair, color)
+	require.Equal(balanceTracker.env.T, expectedDelta, delta, "Unexpected change of balance of %s in L1 and chains for color %s",
+		balanceTracker.keyPairManager.NameOfAddress(address), balanceTracker.keyPairManager.NameOfColor(color))
+}
+
+// GetDelta returns by how much the balance of 'color' of the key pair, summed over L1 and its accounts in every tracked chain, changed
+// since tracking started. Fails test if the key pair is not tracked.
+func (balanceTracker *BalanceTracker) GetDelta(keyPair *ed25519.KeyPair, color colored.Color) int64 {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	delta := balanceTracker.getL1Delta(address, color)
+	for chain := range balanceTracker.accountBalances {
+		delta += balanceTracker.GetAgentDelta(iscp.NewAgentID(address, 0), chain, color)
+	}
+	return delta
+}
+
+// RequireL1Delta verifies if the balance of 'color' of the key pair in L1 changed by 'expectedDelta' since tracking started.
+// Fails test if the key pair is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireL1Delta(keyPair *ed25519.KeyPair, color colored.Color, expectedDelta int64) {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	delta := balanceTracker.GetL1Delta(keyPair, color)
+	require.Equal(balanceTracker.env.T, expectedDelta, delta, "Unexpected change of balance of %s in L1 for color %s",
+		balanceTracker.keyPairManager.NameOfAddress(address), balanceTracker.keyPairManager.NameOfColor(color))
+}
+
+// GetL1Delta returns by how much the balance of 'color' of the key pair in L1 changed since tracking started. Fails test if the key pair is not tracked.
+func (balanceTracker *BalanceTracker) GetL1Delta(keyPair *ed25519.KeyPair, color colored.Color) int64 {
+	return balanceTracker.getL1Delta(ledgerstate.NewED25519Address(keyPair.PublicKey), color)
+}
+
+// RequireChainL1Delta verifies if the balance of 'color' of the address of 'chain' in L1 changed by 'expectedDelta' since tracking started.
+// Fails test if the chain is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireChainL1Delta(chain *solo.Chain, color colored.Color, expectedDelta int64) {
+	delta := balanceTracker.GetChainL1Delta(chain, color)
+	require.Equal(balanceTracker.env.T, expectedDelta, delta, "Unexpected change of balance of chain %s in L1 for color %s",
+		chain.Name, balanceTracker.keyPairManager.NameOfColor(color))
+}
+
+// GetChainL1Delta returns by how much the balance of 'color' of the address of 'chain' in L1 changed since tracking started.
+// Fails test if the chain is not tracked.
+func (balanceTracker *BalanceTracker) GetChainL1Delta(chain *solo.Chain, color colored.Color) int64 {
+	return balanceTracker.getL1Delta(chain.ChainID.AsAddress(), color)
+}
+
+func (balanceTracker *BalanceTracker) getL1Delta(address ledgerstate.Address, color colored.Color) int64 {
+	initialBalances, ok := balanceTracker.l1Balances[address.Base58()]
+	require.True(balanceTracker.env.T, ok, "Balances of %s in L1 are not tracked", balanceTracker.keyPairManager.NameOfAddress(address))
+
+	return delta(initialBalances[color], balanceTracker.env.GetAddressBalance(address, color))
+}
+
+// RequireChainDelta verifies if the balance of 'color' of the key pair in 'chain' changed by 'expectedDelta' since tracking started.
+// Fails test if the key pair or the chain is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireChainDelta(keyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, expectedDelta int64) {
+	agentID := iscp.NewAgentID(ledgerstate.NewED25519Address(keyPair.PublicKey), 0)
+	balanceTracker.RequireAgentDelta(agentID, chain, color, expectedDelta)
+}
+
+// RequireContractDelta verifies if the balance of 'color' of 'contract' in 'chain' changed by 'expectedDelta' since tracking started.
+// Fails test if the contract or the chain is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireContractDelta(chain *solo.Chain, contractName string, color colored.Color, expectedDelta int64) {
+	balanceTracker.RequireAgentDelta(chain.ContractAgentID(contractName), chain, color, expectedDelta)
+}
+
+// RequireChainAccountDelta verifies if the balance of 'color' of the own account of 'chain' changed by 'expectedDelta' since tracking started.
+// Fails test if the chain is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireChainAccountDelta(chain *solo.Chain, color colored.Color, expectedDelta int64) {
+	balanceTracker.RequireAgentDelta(iscp.NewAgentID(chain.ChainID.AsAddress(), 0), chain, color, expectedDelta)
+}
+
+// RequireAgentDelta verifies if the balance of 'color' of 'agentID' in 'chain' changed by 'expectedDelta' since tracking started.
+// Fails test if the agent or the chain is not tracked or the balance changed by another amount.
+func (balanceTracker *BalanceTracker) RequireAgentDelta(agentID *iscp.AgentID, chain *solo.Chain, color colored.Color, expectedDelta int64) {
+	delta := balanceTracker.GetAgentDelta(agentID, chain, color)
+	require.Equal(balanceTracker.env.T, expectedDelta, delta, "Unexpected change of balance of %s in chain %s for color %s",
+		balanceTracker.keyPairManager.NameOfAgentID(agentID), chain.Name, balanceTracker.keyPairManager.NameOfColor(color))
+}
+
+// GetAgentDelta returns by how much the balance of 'color' of 'agentID' in 'chain' changed since tracking started.
+// Fails test if the agent or the chain is not tracked.
+func (balanceTracker *BalanceTracker) GetAgentDelta(agentID *iscp.AgentID, chain *solo.Chain, color colored.Color) int64 {
+	accountBalances, ok := balanceTracker.accountBalances[chain]
+	require.True(balanceTracker.env.T, ok, "Chain %s is not tracked", chain.Name)
+	initialBalances, ok := accountBalances[agentID.String()]
+	require.True(balanceTracker.env.T, ok, "Balances of %s in chain %s are not tracked", balanceTracker.keyPairManager.NameOfAgentID(agentID), chain.Name)
+
+	return delta(initialBalances[color], getAccountBalances(chain, agentID)[color])
+}
+
+func getAccountBalances(chain *solo.Chain, agentID *iscp.AgentID) colored.Balances {
+	balances := chain.GetAccountBalance(agentID)
+	if balances == nil {
+		return colored.Balances{}
+	}
+	return balances
+}
+
+func delta(initialBalance uint64, balance uint64) int64 {
+	return int64(balance) - int64(initialBalance)
+}
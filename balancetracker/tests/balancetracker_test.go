@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"testing"
+
+	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/vm/core/accounts"
+)
+
+func Test_RequireDelta(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and key pairs with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	aliceKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	bobKeyPair := notSolo.KeyPair.NamedWithFunds("bob")
+	transferAmount := uint64(100)
+
+	// Track balances and send some funds to chain
+	balanceTracker := notSolo.TrackBalances(aliceKeyPair, bobKeyPair, chain)
+	notSolo.L1.MustTransferToChain(aliceKeyPair, chain, colored.IOTA, transferAmount, bobKeyPair)
+
+	balanceTracker.RequireL1Delta(aliceKeyPair, colored.IOTA, -int64(transferAmount))
+	balanceTracker.RequireL1Delta(bobKeyPair, colored.IOTA, 0)
+	balanceTracker.RequireChainDelta(aliceKeyPair, chain, colored.IOTA, 0)
+	balanceTracker.RequireChainDelta(bobKeyPair, chain, colored.IOTA, int64(transferAmount))
+	balanceTracker.RequireChainL1Delta(chain, colored.IOTA, int64(transferAmount))
+
+	// The delta of a key pair sums L1 and its accounts in the tracked chains
+	balanceTracker.RequireDelta(aliceKeyPair, colored.IOTA, -int64(transferAmount))
+	balanceTracker.RequireDelta(bobKeyPair, colored.IOTA, int64(transferAmount))
+}
+
+func Test_RequireDelta_AcrossL1AndChain(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with funds in it
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	aliceKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	notSolo.L1.MustTransferToChainToSelf(aliceKeyPair, chain, colored.IOTA, 100)
+
+	// Moving tokens between L1 and the account of the key pair does not change its delta
+	balanceTracker := notSolo.TrackBalances(aliceKeyPair, chain)
+	notSolo.Chain.MustTransferToL1ToSelf(aliceKeyPair, chain, colored.IOTA, 40)
+
+	balanceTracker.RequireL1Delta(aliceKeyPair, colored.IOTA, 40)
+	balanceTracker.RequireChainDelta(aliceKeyPair, chain, colored.IOTA, -40)
+	balanceTracker.RequireDelta(aliceKeyPair, colored.IOTA, 0)
+}
+
+func Test_RequireChainAccountDelta(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with dummy funds
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	aliceKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	chainAgentID := iscp.NewAgentID(chain.ChainID.AsAddress(), 0)
+
+	// Send funds to the own account of the chain
+	balanceTracker := notSolo.TrackBalances(aliceKeyPair, chain)
+	notSolo.L1.MustTransferToAgent(aliceKeyPair, chain, colored.IOTA, 100, chainAgentID)
+
+	balanceTracker.RequireChainAccountDelta(chain, colored.IOTA, 100)
+	balanceTracker.RequireChainL1Delta(chain, colored.IOTA, 100)
+	balanceTracker.RequireL1Delta(aliceKeyPair, colored.IOTA, -100)
+}
+
+func Test_RequireContractDelta(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with dummy funds
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	aliceKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+
+	// Send funds to the account of a contract of the chain
+	balanceTracker := notSolo.TrackBalances(aliceKeyPair, chain, accounts.Contract.Name)
+	notSolo.L1.MustTransferToContract(aliceKeyPair, chain, colored.IOTA, 100, accounts.Contract.Name)
+
+	balanceTracker.RequireContractDelta(chain, accounts.Contract.Name, colored.IOTA, 100)
+	balanceTracker.RequireChainDelta(aliceKeyPair, chain, colored.IOTA, 0)
+}
+
+func Test_RequireAgentDelta(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain, a key pair with dummy funds and an agent which belongs to no key pair of the manager
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	aliceKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	externalKeyPair := ed25519.GenerateKeyPair()
+	agentID := iscp.NewAgentID(ledgerstate.NewED25519Address(externalKeyPair.PublicKey), 0)
+
+	// Send funds to the account of the agent
+	balanceTracker := notSolo.TrackBalances(agentID, chain)
+	notSolo.L1.MustTransferToAgent(aliceKeyPair, chain, colored.IOTA, 100, agentID)
+
+	balanceTracker.RequireAgentDelta(agentID, chain, colored.IOTA, 100)
+	balanceTracker.RequireChainL1Delta(chain, colored.IOTA, 100)
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 
 	"github.com/brunoamancio/NotSolo/constants"
+	"github.com/brunoamancio/NotSolo/keypairmanager"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/brunoamancio/NotSolo/requestobserver"
 	"github.com/iotaledger/goshimmer/packages/ledgerstate"
-	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
 	"github.com/iotaledger/hive.go/crypto/ed25519"
 	"github.com/iotaledger/wasp/packages/iscp"
 	"github.com/iotaledger/wasp/packages/iscp/colored"
@@ -16,35 +18,58 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// ErrInsufficientFunds is returned when an account in a chain does not hold the tokens to transfer plus the fees of the transfer
+var ErrInsufficientFunds = errors.New("insufficient funds in chain account")
+
 // ChainManager manipulates chains
 type ChainManager struct {
-	env    *solo.Solo
-	chains map[string]*solo.Chain
+	env            *solo.Solo
+	keyPairManager *keypairmanager.KeyPairManager
+	chains         map[string]*solo.Chain
+	observers      requestobserver.Observers
 }
 
-// Dispose implements Disposable for ChainManager
+// Dispose implements Disposable for ChainManager. Forgets the chains created so far.
+// Important: Solo (at the wasp version used by NotSolo) offers no way to stop a chain. The goroutines which read and process its requests
+// run until the test binary exits, and its outputs stay in the L1 ledger. They cannot be released here, so the chains are only forgotten.
 func (chainManager *ChainManager) Dispose() {
 	chainManager.chains = make(map[string]*solo.Chain)
 }
 
+// GetChains returns all chains created by the manager, by name
+func (chainManager *ChainManager) GetChains() map[string]*solo.Chain {
+	chains := make(map[string]*solo.Chain, len(chainManager.chains))
+	for chainName, chain := range chainManager.chains {
+		chains[chainName] = chain
+	}
+	return chains
+}
+
 // New instantiates a chain manager
-func New(env *solo.Solo) *ChainManager {
-	chainManager := &ChainManager{env: env, chains: make(map[string]*solo.Chain)}
+func New(env *solo.Solo, keyPairManager *keypairmanager.KeyPairManager) *ChainManager {
+	chainManager := &ChainManager{env: env, keyPairManager: keyPairManager, chains: make(map[string]*solo.Chain)}
 	return chainManager
 }
 
+// AddRequestObserver makes 'observer' be notified of every request posted by the manager
+func (chainManager *ChainManager) AddRequestObserver(observer requestobserver.RequestObserver) {
+	chainManager.observers.Add(observer)
+}
+
 // NewChain instantiates a new chain with initial balance equal to 'expectedChainIdBalance' which is debited from the chainOriginator's balance.
-//   If 'chainOriginator' is nil, a new KeyPair is generated and 'utxodb.RequestFundsAmount' IOTA tokens are assigned to it
-//   If 'validatorFeeTarget' is skipped, it is assumed equal to the chainOriginators AgentID
 //
-//   Fails test on error.
+//	If 'chainOriginator' is nil, a new KeyPair is generated from the master seed and 'utxodb.RequestFundsAmount' IOTA tokens are assigned to it
+//	If 'validatorFeeTarget' is skipped, it is assumed equal to the chainOriginators AgentID
+//
+//	Fails test on error.
 func (chainManager *ChainManager) NewChain(chainOriginatorKeyPair *ed25519.KeyPair, chainName string, validatorFeeTarget ...*iscp.AgentID) *solo.Chain {
 
-	initialOriginatorBalanceInL1 := uint64(0)
-	if chainOriginatorKeyPair != nil {
-		chainOriginatorAddress := ledgerstate.NewED25519Address(chainOriginatorKeyPair.PublicKey)
-		initialOriginatorBalanceInL1 = chainManager.env.GetAddressBalance(chainOriginatorAddress, colored.IOTA)
+	// IMPORTANT: The originator is generated by the key pair manager, so that its address is the same in every run with the same seed
+	if chainOriginatorKeyPair == nil {
+		chainOriginatorKeyPair = chainManager.keyPairManager.NewKeyPairWithFunds()
 	}
+	chainOriginatorAddress := ledgerstate.NewED25519Address(chainOriginatorKeyPair.PublicKey)
+	initialOriginatorBalanceInL1 := chainManager.env.GetAddressBalance(chainOriginatorAddress, colored.IOTA)
 
 	newChain := chainManager.env.NewChain(chainOriginatorKeyPair, chainName, validatorFeeTarget...)
 	require.NotNil(chainManager.env.T, newChain, "Could not instantiate a new chain")
@@ -52,21 +77,17 @@ func (chainManager *ChainManager) NewChain(chainOriginatorKeyPair *ed25519.KeyPa
 	// IMPORTANT: When a chain is created >>> USING SOLO <<<, a default amount of IOTA is sent to ChainID in L1
 	// Another IOTA is consumed by the request and also sent to ChainID
 	expectedChainIdBalance := constants.DefaultChainStartingBalance + constants.IotaTokensConsumedByRequest
-	chainManager.env.AssertAddressBalance(newChain.ChainID.AsAddress(), colored.IOTA, expectedChainIdBalance)
+	chainIdBalance := chainManager.env.GetAddressBalance(newChain.ChainID.AsAddress(), colored.IOTA)
+	require.EqualValues(chainManager.env.T, expectedChainIdBalance, chainIdBalance, "Unexpected balance of chain %s in L1", chainName)
 
 	// IMPORTANT: Originator has no balance in the chain
 	chainManager.RequireBalance(newChain.OriginatorKeyPair, newChain, colored.IOTA, 0)
 
 	// IMPORTANT: Originator has initial balance - the amount transfered from L1
-	chainOriginatorAddress := ledgerstate.NewED25519Address(newChain.OriginatorKeyPair.PublicKey)
-	expectedChainOriginatorBalanceInL1 := uint64(0)
-	if chainOriginatorKeyPair == nil {
-		expectedChainOriginatorBalanceInL1 = utxodb.RequestFundsAmount - expectedChainIdBalance
-	} else {
-		expectedChainOriginatorBalanceInL1 = initialOriginatorBalanceInL1 - expectedChainIdBalance
-	}
-
-	chainManager.env.AssertAddressBalance(chainOriginatorAddress, colored.IOTA, expectedChainOriginatorBalanceInL1)
+	expectedChainOriginatorBalanceInL1 := initialOriginatorBalanceInL1 - expectedChainIdBalance
+	chainOriginatorBalanceInL1 := chainManager.env.GetAddressBalance(chainOriginatorAddress, colored.IOTA)
+	require.EqualValues(chainManager.env.T, expectedChainOriginatorBalanceInL1, chainOriginatorBalanceInL1, "Unexpected balance of %s, originator of chain %s, in L1",
+		chainManager.keyPairManager.NameOf(newChain.OriginatorKeyPair), chainName)
 
 	// Expect zero initial fees
 	feeColor, ownerFee, validatorFee := newChain.GetFeeInfo(accounts.Contract.Name)
@@ -115,7 +136,7 @@ func changeFee(chainManager *ChainManager, authorizedKeyPair *ed25519.KeyPair, c
 	oldFeeColor, oldChainOwnerFee, oldValidatorFee = chain.GetFeeInfo(contractName)
 
 	request := solo.NewCallParams(governance.Contract.Name, governance.FuncSetContractFee.Name, governance.ParamHname, contractRecord.Hname(), feeParam, newFee).WithIotas(constants.IotaTokensConsumedByRequest)
-	_, err = chain.PostRequestSync(request, authorizedKeyPair)
+	_, err = chainManager.observers.PostRequestSync(chain, request, authorizedKeyPair)
 	require.NoError(chainManager.env.T, err)
 
 	return oldFeeColor, oldChainOwnerFee, oldValidatorFee
@@ -123,8 +144,8 @@ func changeFee(chainManager *ChainManager, authorizedKeyPair *ed25519.KeyPair, c
 
 // Harvest allows the 'chain owner' to withdraw funds from 'chain'to his account in the same 'chain'. This request costs 'constants.IotaTokensConsumedByRequest' IOTA token.
 func (chainManager *ChainManager) Harvest(chain *solo.Chain, color colored.Color, withdrawalAmount uint64) {
-	request := solo.NewCallParams(accounts.Contract.Name, accounts.FuncHarvest.Name, accounts.ParamWithdrawAmount, withdrawalAmount, accounts.ParamWithdrawColor, colored.IOTA).WithIotas(constants.IotaTokensConsumedByRequest)
-	_, err := chain.PostRequestSync(request, chain.OriginatorKeyPair)
+	request := solo.NewCallParams(accounts.Contract.Name, accounts.FuncHarvest.Name, accounts.ParamWithdrawAmount, withdrawalAmount, accounts.ParamWithdrawColor, color).WithIotas(constants.IotaTokensConsumedByRequest)
+	_, err := chainManager.observers.PostRequestSync(chain, request, chain.OriginatorKeyPair)
 	require.NoError(chainManager.env.T, err, "Could not harvest funds.")
 }
 
@@ -132,7 +153,9 @@ func (chainManager *ChainManager) Harvest(chain *solo.Chain, color colored.Color
 func (chainManager *ChainManager) GrantDeployPermission(chain *solo.Chain, authorizedKeyPair *ed25519.KeyPair) {
 	authorizedAddress := ledgerstate.NewED25519Address(authorizedKeyPair.PublicKey)
 	authorizedAgentID := iscp.NewAgentID(authorizedAddress, 0)
-	err := chain.GrantDeployPermission(nil, *authorizedAgentID)
+	err := chainManager.observers.Notify(chain, nil, func() error {
+		return chain.GrantDeployPermission(nil, *authorizedAgentID)
+	})
 	require.NoError(chainManager.env.T, err, "Could not grant deploy permission")
 }
 
@@ -140,19 +163,25 @@ func (chainManager *ChainManager) GrantDeployPermission(chain *solo.Chain, autho
 func (chainManager *ChainManager) RevokeDeployPermission(chain *solo.Chain, authorizedKeyPair *ed25519.KeyPair) {
 	authorizedAddress := ledgerstate.NewED25519Address(authorizedKeyPair.PublicKey)
 	authorizedAgentID := iscp.NewAgentID(authorizedAddress, 0)
-	err := chain.RevokeDeployPermission(nil, *authorizedAgentID)
+	err := chainManager.observers.Notify(chain, nil, func() error {
+		return chain.RevokeDeployPermission(nil, *authorizedAgentID)
+	})
 	require.NoError(chainManager.env.T, err, "Could not revoke deploy permission")
 }
 
 // GrantAgentDeployPermission gives permission, as the chain originator, to 'authorizedAgentID' to deploy SCs into the specified chain. Fails test on error.
 func (chainManager *ChainManager) GrantAgentDeployPermission(chain *solo.Chain, authorizedAgentID iscp.AgentID) {
-	err := chain.GrantDeployPermission(nil, authorizedAgentID)
+	err := chainManager.observers.Notify(chain, nil, func() error {
+		return chain.GrantDeployPermission(nil, authorizedAgentID)
+	})
 	require.NoError(chainManager.env.T, err, "Could not grant deploy permission")
 }
 
 // RevokeAgentDeployPermission revokes permission, as the chain originator, from 'authorizedAgentID' to deploy SCs into 'chain'. Fails test on error.
 func (chainManager *ChainManager) RevokeAgentDeployPermission(chain *solo.Chain, authorizedAgentID iscp.AgentID) {
-	err := chain.RevokeDeployPermission(nil, authorizedAgentID)
+	err := chainManager.observers.Notify(chain, nil, func() error {
+		return chain.RevokeDeployPermission(nil, authorizedAgentID)
+	})
 	require.NoError(chainManager.env.T, err, "Could not revoke deploy permission")
 }
 
@@ -168,7 +197,9 @@ func (chainManager *ChainManager) MustGetAgentID(chain *solo.Chain, contractName
 
 // DeployWasmContract uploads and deploys 'constract wasm file'
 func (chainManager *ChainManager) DeployWasmContract(chain *solo.Chain, contractOriginatorKeyPair *ed25519.KeyPair, contractName string, contractWasmFilePath string) {
-	err := chain.DeployWasmContract(contractOriginatorKeyPair, contractName, contractWasmFilePath)
+	err := chainManager.observers.Notify(chain, nil, func() error {
+		return chain.DeployWasmContract(contractOriginatorKeyPair, contractName, contractWasmFilePath)
+	})
 	require.NoError(chainManager.env.T, err, "Could not deploy wasm contract")
 }
 
@@ -198,36 +229,142 @@ func (chainManager *ChainManager) MustGetContractRecord(chain *solo.Chain, contr
 
 // MustTransferToL1ToSelf makes transfer of 'amount' of 'color' from the depositors account in 'chain' to the depositors address in L1.
 // Fails test on error.
-// Important: Due to the current logic in the accounts contract (from IOTA Foundation), all tokens of 'depositor' are withdrawn from 'chain' to his address in L1.
 func (chainManager *ChainManager) MustTransferToL1ToSelf(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64) {
 	err := chainManager.TransferToL1ToSelf(depositorKeyPair, chain, color, transferAmount)
-	require.NoError(chainManager.env.T, err)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s to L1", chainManager.keyPairManager.NameOf(depositorKeyPair))
 }
 
 // TransferToL1ToSelf makes transfer of 'amount' of 'color' from the depositors account in 'chain' to the depositors address in L1.
-// Important: Due to the current logic in the accounts contract (from IOTA Foundation), all tokens of 'depositor' are withdrawn from 'chain' to his address in L1.
 func (chainManager *ChainManager) TransferToL1ToSelf(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64) error {
+	return chainManager.TransferToL1(depositorKeyPair, chain, color, transferAmount, nil)
+}
 
-	request := solo.NewCallParams(accounts.Contract.Name, accounts.FuncWithdraw.Name).WithIotas(transferAmount)
-	_, err := chain.PostRequestSync(request, depositorKeyPair)
+// MustTransferToL1 makes transfer of 'amount' of 'color' from the depositors account in 'chain' to 'receiverAddress' in L1.
+// Transfers to 'depositor' if no receiver is defined. The rest of the depositors account stays in 'chain'. Fails test on error.
+func (chainManager *ChainManager) MustTransferToL1(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+	receiverAddress ledgerstate.Address) {
+	err := chainManager.TransferToL1(depositorKeyPair, chain, color, transferAmount, receiverAddress)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s to L1", chainManager.keyPairManager.NameOf(depositorKeyPair))
+}
 
-	return err
+// TransferToL1 makes transfer of 'amount' of 'color' from the depositors account in 'chain' to 'receiverAddress' in L1.
+// Transfers to 'depositor' if no receiver is defined. The rest of the depositors account stays in 'chain'.
+// Returns ErrInsufficientFunds if the depositors account does not hold 'amount' of 'color' plus the fees. See TransferBalancesToL1.
+func (chainManager *ChainManager) TransferToL1(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+	receiverAddress ledgerstate.Address) error {
+	return chainManager.TransferBalancesToL1(depositorKeyPair, chain, colored.Balances{color: transferAmount}, receiverAddress)
+}
+
+// MustTransferBalancesToL1 makes transfer of 'balances' (any number of colors) from the depositors account in 'chain' to 'receiverAddress' in L1.
+// Transfers to 'depositor' if no receiver is defined. The rest of the depositors account stays in 'chain'. Fails test on error.
+func (chainManager *ChainManager) MustTransferBalancesToL1(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, balances colored.Balances,
+	receiverAddress ledgerstate.Address) {
+	err := chainManager.TransferBalancesToL1(depositorKeyPair, chain, balances, receiverAddress)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s to L1", chainManager.keyPairManager.NameOf(depositorKeyPair))
+}
+
+// TransferBalancesToL1 makes transfer of 'balances' (any number of colors) from the depositors account in 'chain' to 'receiverAddress' in L1.
+// Transfers to 'depositor' if no receiver is defined. The rest of the depositors account stays in 'chain'.
+// Returns ErrInsufficientFunds if the depositors account does not hold 'balances' plus the fees of the accounts contract.
+// Important: The accounts contract (from IOTA Foundation) cannot withdraw a partial amount. It only withdraws all tokens of 'depositor'
+// to his address in L1. So one transfer takes up to three steps, each seen by receipts, balance trackers and the conservation checker.
+// First, an off-ledger request withdraws the whole account to the depositors address in L1, paying its fees from the account. Then, if
+// anything is left beyond 'balances', a second request deposits it back to the depositors account, paying its fees from the tokens it returns.
+// Last, if the receiver is not 'depositor', 'balances' are transferred in L1 from the depositors address to 'receiverAddress'.
+// The tokens therefore pass through the depositors address in L1, but his balance there does not go down.
+func (chainManager *ChainManager) TransferBalancesToL1(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, balances colored.Balances,
+	receiverAddress ledgerstate.Address) error {
+
+	depositorAddress := ledgerstate.NewED25519Address(depositorKeyPair.PublicKey)
+	isReceiverDefined := receiverAddress != nil
+
+	if !isReceiverDefined {
+		receiverAddress = depositorAddress
+	}
+
+	// The withdrawal always pays fees. The deposit of the rest pays them too, if there is a rest.
+	feeColor, ownerFee, validatorFee := chain.GetFeeInfo(accounts.Contract.Name)
+	fee := ownerFee + validatorFee
+	remainingBalances := chainManager.GetChainBalances(depositorKeyPair, chain)
+	for color, transferAmount := range balances {
+		if remainingBalances[color] < transferAmount {
+			return ErrInsufficientFunds
+		}
+		remainingBalances[color] -= transferAmount
+	}
+	if remainingBalances[feeColor] < fee {
+		return ErrInsufficientFunds
+	}
+	remainingBalances[feeColor] -= fee
+	if !isEmpty(remainingBalances) && remainingBalances[feeColor] < fee {
+		return ErrInsufficientFunds
+	}
+
+	// Withdraw all tokens from 'chain' to depositor's address in L1
+	l1BalancesBefore := chainManager.env.GetAddressBalances(depositorAddress)
+	request := solo.NewCallParams(accounts.Contract.Name, accounts.FuncWithdraw.Name)
+	_, err := chainManager.observers.PostRequestOffLedger(chain, request, depositorKeyPair)
+	if err != nil {
+		return err
+	}
+
+	// Deposit what exceeds 'balances' back to depositor's account in 'chain'
+	withdrawnBalances := chainManager.env.GetAddressBalances(depositorAddress)
+	for color, balanceBefore := range l1BalancesBefore {
+		withdrawnBalances[color] -= balanceBefore
+	}
+	for color, transferAmount := range balances {
+		if withdrawnBalances[color] < transferAmount {
+			return ErrInsufficientFunds
+		}
+		withdrawnBalances[color] -= transferAmount
+	}
+	if !isEmpty(withdrawnBalances) {
+		request = solo.NewCallParams(accounts.Contract.Name, accounts.FuncDeposit.Name).WithTransfers(nonZero(withdrawnBalances))
+		_, err = chainManager.observers.PostRequestSync(chain, request, depositorKeyPair)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Transfer from depositor's address in L1 to receiver's address in L1
+	if receiverAddress.Equals(depositorAddress) {
+		return nil
+	}
+	return chainManager.observers.Notify(nil, []ledgerstate.Address{depositorAddress, receiverAddress}, func() error {
+		return l1ledger.Transfer(chainManager.env, depositorKeyPair, receiverAddress, balances)
+	})
 }
 
 // MustTransferBetweenChains makes transfer of 'amount' of 'color' from the depositors account in 'sourceChain' to the receivers account in 'destinationChain'.
 // Transfers to 'depositor' if no receiver is defined. Fails test on error.
-// Important: Due to the current logic in the accounts contract (from IOTA Foundation), all tokens of 'depositor' are withdrawn from 'sourceChain' to his address in L1.
 func (chainManager *ChainManager) MustTransferBetweenChains(depositorKeyPair *ed25519.KeyPair, sourceChain *solo.Chain, color colored.Color, transferAmount uint64,
 	destinationChain *solo.Chain, receiverKeyPair *ed25519.KeyPair) {
 	err := chainManager.TransferBetweenChains(depositorKeyPair, sourceChain, color, transferAmount, destinationChain, receiverKeyPair)
-	require.NoError(chainManager.env.T, err)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s from chain %s to chain %s", chainManager.keyPairManager.NameOf(depositorKeyPair),
+		sourceChain.Name, destinationChain.Name)
 }
 
 // TransferBetweenChains makes transfer of 'amount' of 'color' from the depositors account in 'sourceChain' to the receivers account in 'destinationChain'.
-// Transfers to 'depositor' if no receiver is defined.
-// Important: Due to the current logic in the accounts contract (from IOTA Foundation), all tokens of 'depositor' are withdrawn from 'sourceChain' to his address in L1.
+// Transfers to 'depositor' if no receiver is defined. The tokens pass through the depositor's address in L1.
 func (chainManager *ChainManager) TransferBetweenChains(depositorKeyPair *ed25519.KeyPair, sourceChain *solo.Chain, color colored.Color, transferAmount uint64,
 	destinationChain *solo.Chain, receiverKeyPair *ed25519.KeyPair) error {
+	return chainManager.TransferBalancesBetweenChains(depositorKeyPair, sourceChain, colored.Balances{color: transferAmount}, destinationChain, receiverKeyPair)
+}
+
+// MustTransferBalancesBetweenChains makes transfer of 'balances' (any number of colors) from the depositors account in 'sourceChain' to the receivers
+// account in 'destinationChain'. Transfers to 'depositor' if no receiver is defined. Fails test on error.
+func (chainManager *ChainManager) MustTransferBalancesBetweenChains(depositorKeyPair *ed25519.KeyPair, sourceChain *solo.Chain, balances colored.Balances,
+	destinationChain *solo.Chain, receiverKeyPair *ed25519.KeyPair) {
+	err := chainManager.TransferBalancesBetweenChains(depositorKeyPair, sourceChain, balances, destinationChain, receiverKeyPair)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s from chain %s to chain %s", chainManager.keyPairManager.NameOf(depositorKeyPair),
+		sourceChain.Name, destinationChain.Name)
+}
+
+// TransferBalancesBetweenChains makes transfer of 'balances' (any number of colors) from the depositors account in 'sourceChain' to the receivers
+// account in 'destinationChain'. Transfers to 'depositor' if no receiver is defined. The tokens pass through the depositor's address in L1.
+func (chainManager *ChainManager) TransferBalancesBetweenChains(depositorKeyPair *ed25519.KeyPair, sourceChain *solo.Chain, balances colored.Balances,
+	destinationChain *solo.Chain, receiverKeyPair *ed25519.KeyPair) error {
 
 	isReceiverDefined := receiverKeyPair != nil
 
@@ -236,7 +373,7 @@ func (chainManager *ChainManager) TransferBetweenChains(depositorKeyPair *ed2551
 	}
 
 	// Transfer from 'sourceChain' to depositor's account in L1
-	err := chainManager.TransferToL1ToSelf(depositorKeyPair, sourceChain, color, transferAmount)
+	err := chainManager.TransferBalancesToL1(depositorKeyPair, sourceChain, balances, nil)
 
 	if err != nil {
 		return err
@@ -247,15 +384,14 @@ func (chainManager *ChainManager) TransferBetweenChains(depositorKeyPair *ed2551
 	receiverAgentID := iscp.NewAgentID(receiverAddress, 0)
 
 	request := solo.NewCallParams(accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, receiverAgentID).
-		WithTransfer(color, transferAmount)
-	_, err = destinationChain.PostRequestSync(request, depositorKeyPair)
+		WithTransfers(balances)
+	_, err = chainManager.observers.PostRequestSync(destinationChain, request, depositorKeyPair)
 
 	return err
 }
 
 // TransferWithinChain makes transfer of 'amount' of 'color' from the depositors account in 'chain' to the receivers account in the same chain.
 // Nothing is transfered if no receiver is defined.
-// Important: Due to the current logic in the accounts contract (from IOTA Foundation), all tokens are withdrawn from 'sourceChain', not only the specified color and amount.
 func (chainManager *ChainManager) TransferWithinChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
 	receiverKeyPair *ed25519.KeyPair) error {
 	isReceiverDefined := receiverKeyPair != nil
@@ -264,18 +400,44 @@ func (chainManager *ChainManager) TransferWithinChain(depositorKeyPair *ed25519.
 		return errors.New("receiver not defined")
 	}
 
-	// Transfer from depositor's account in 'chain' to the receiver's account in the same chain.
-	err := chainManager.TransferBetweenChains(depositorKeyPair, chain, color, transferAmount, chain, receiverKeyPair)
-	return err
+	receiverAgentID := chainManager.keyPairManager.MustGetAgentID(receiverKeyPair)
+	return chainManager.TransferToAgentWithinChain(depositorKeyPair, chain, color, transferAmount, &receiverAgentID)
 }
 
 // MustTransferWithinChain makes transfer of 'amount' of 'color' from the depositors account in 'chain' to the receivers account in the same chain.
 // Nothing is transfered if no receiver is defined. Fails test on error.
-// Important: Due to the current logic in the accounts contract (from IOTA Foundation), all tokens are withdrawn from 'sourceChain', not only the specified color and amount.
 func (chainManager *ChainManager) MustTransferWithinChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
 	receiverKeyPair *ed25519.KeyPair) {
 	err := chainManager.TransferWithinChain(depositorKeyPair, chain, color, transferAmount, receiverKeyPair)
-	require.NoError(chainManager.env.T, err)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s within chain %s", chainManager.keyPairManager.NameOf(depositorKeyPair), chain.Name)
+}
+
+// TransferToAgentWithinChain makes transfer of 'amount' of 'color' from the depositors account in 'chain' to the account of 'receiverAgentID'
+// (a key pair, a contract or a chain) in the same chain. The transfer is an off-ledger request, so nothing changes in L1.
+func (chainManager *ChainManager) TransferToAgentWithinChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+	receiverAgentID *iscp.AgentID) error {
+	if receiverAgentID == nil {
+		return errors.New("receiver not defined")
+	}
+
+	if chainManager.GetChainBalance(depositorKeyPair, chain, color) < transferAmount {
+		return l1ledger.ErrInsufficientFunds
+	}
+
+	// Move tokens from depositor's account in 'chain' to the receiver's account in the same chain
+	request := solo.NewCallParams(accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, receiverAgentID).
+		WithTransfer(color, transferAmount)
+	_, err := chainManager.observers.PostRequestOffLedger(chain, request, depositorKeyPair)
+	return err
+}
+
+// MustTransferToAgentWithinChain makes transfer of 'amount' of 'color' from the depositors account in 'chain' to the account of 'receiverAgentID'
+// (a key pair, a contract or a chain) in the same chain. Fails test on error.
+func (chainManager *ChainManager) MustTransferToAgentWithinChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+	receiverAgentID *iscp.AgentID) {
+	err := chainManager.TransferToAgentWithinChain(depositorKeyPair, chain, color, transferAmount, receiverAgentID)
+	require.NoError(chainManager.env.T, err, "Could not complete transfer of %s to %s within chain %s", chainManager.keyPairManager.NameOf(depositorKeyPair),
+		chainManager.nameOfAgentID(receiverAgentID), chain.Name)
 }
 
 // RequireBalance verifies if the key pair has the expected balance of 'color' in 'chain'.
@@ -284,21 +446,78 @@ func (chainManager *ChainManager) RequireBalance(keyPair *ed25519.KeyPair, chain
 	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
 	agentID := iscp.NewAgentID(address, 0)
 
-	chain.AssertAccountBalance(agentID, color, expectedBalance)
+	chainManager.requireAccountBalance(chain, agentID, color, expectedBalance)
 }
 
-// RequireBalance verifies if chain's 'agentID' has the expected balance of 'color' in the 'chain' itself.
+// RequireChainBalance verifies if chain's 'agentID' has the expected balance of 'color' in the 'chain' itself.
 // Fails test if balance is not equal to expectedBalance.
 func (chainManager *ChainManager) RequireChainBalance(chain *solo.Chain, color colored.Color, expectedBalance uint64) {
 	chainAddress := chain.ChainID.AsAddress()
 	chainAgentID := iscp.NewAgentID(chainAddress, 0)
 
-	chain.AssertAccountBalance(chainAgentID, colored.IOTA, expectedBalance)
+	chainManager.requireAccountBalance(chain, chainAgentID, color, expectedBalance)
+}
+
+// RequireChainBalances verifies if chain's 'agentID' has the expected balances in the 'chain' itself.
+// Fails test if the balance of any color is not equal to the one in expectedBalances. Colors missing in expectedBalances are expected to be zero.
+func (chainManager *ChainManager) RequireChainBalances(chain *solo.Chain, expectedBalances colored.Balances) {
+	chainAddress := chain.ChainID.AsAddress()
+	chainAgentID := iscp.NewAgentID(chainAddress, 0)
+
+	balances := chainManager.GetAgentBalances(chainAgentID, chain)
+	for color := range balances {
+		chainManager.requireAccountBalance(chain, chainAgentID, color, expectedBalances[color])
+	}
+	for color, expectedBalance := range expectedBalances {
+		chainManager.requireAccountBalance(chain, chainAgentID, color, expectedBalance)
+	}
 }
 
 // RequireContractBalance verifies if 'contract' has the expected balance of 'color' in 'chain'.
 // Fails test if contract is neither defined, nor found, or if the balance is not equal to expectedBalance.
 func (chainManager *ChainManager) RequireContractBalance(chain *solo.Chain, contractName string, color colored.Color, expectedBalance uint64) {
+	contractAgentID := chainManager.mustGetContractAgentID(chain, contractName)
+	chainManager.requireAccountBalance(chain, contractAgentID, color, expectedBalance)
+}
+
+// GetChainBalance returns the balance of 'color' of the key pair in 'chain'
+func (chainManager *ChainManager) GetChainBalance(keyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color) uint64 {
+	return chainManager.GetChainBalances(keyPair, chain)[color]
+}
+
+// GetChainBalances returns the balances of all colors of the key pair in 'chain'
+func (chainManager *ChainManager) GetChainBalances(keyPair *ed25519.KeyPair, chain *solo.Chain) colored.Balances {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	return chainManager.GetAgentBalances(iscp.NewAgentID(address, 0), chain)
+}
+
+// GetContractBalance returns the balance of 'color' of 'contract' in 'chain'. Fails test if contract is neither defined, nor found.
+func (chainManager *ChainManager) GetContractBalance(chain *solo.Chain, contractName string, color colored.Color) uint64 {
+	return chainManager.GetContractBalances(chain, contractName)[color]
+}
+
+// GetContractBalances returns the balances of all colors of 'contract' in 'chain'. Fails test if contract is neither defined, nor found.
+func (chainManager *ChainManager) GetContractBalances(chain *solo.Chain, contractName string) colored.Balances {
+	contractAgentID := chainManager.mustGetContractAgentID(chain, contractName)
+	return chainManager.GetAgentBalances(contractAgentID, chain)
+}
+
+// GetAgentBalance returns the balance of 'color' of 'agentID' in 'chain'
+func (chainManager *ChainManager) GetAgentBalance(agentID *iscp.AgentID, chain *solo.Chain, color colored.Color) uint64 {
+	return chainManager.GetAgentBalances(agentID, chain)[color]
+}
+
+// GetAgentBalances returns the balances of all colors of 'agentID' in 'chain'
+func (chainManager *ChainManager) GetAgentBalances(agentID *iscp.AgentID, chain *solo.Chain) colored.Balances {
+	balances := chain.GetAccountBalance(agentID)
+	if balances == nil {
+		return colored.Balances{}
+	}
+	return balances
+}
+
+// mustGetContractAgentID returns the AgentID of 'contract' in 'chain'. Fails test if contract is neither defined, nor found.
+func (chainManager *ChainManager) mustGetContractAgentID(chain *solo.Chain, contractName string) *iscp.AgentID {
 
 	// Get contract record
 	contractRecord, err := chain.FindContract(contractName)
@@ -306,7 +525,50 @@ func (chainManager *ChainManager) RequireContractBalance(chain *solo.Chain, cont
 	require.NotNil(chainManager.env.T, contractRecord, "Contract could not be found")
 
 	// Get contract's AgentID
-	contractAgentID := chain.ContractAgentID(contractRecord.Name)
+	return chain.ContractAgentID(contractRecord.Name)
+}
 
-	chain.AssertAccountBalance(contractAgentID, color, expectedBalance)
+// RequireAgentBalance verifies if 'agentID' has the expected balance of 'color' in 'chain'.
+// Fails test if balance is not equal to expectedBalance.
+func (chainManager *ChainManager) RequireAgentBalance(agentID *iscp.AgentID, chain *solo.Chain, color colored.Color, expectedBalance uint64) {
+	chainManager.requireAccountBalance(chain, agentID, color, expectedBalance)
+}
+
+// requireAccountBalance verifies if 'agentID' has the expected balance of 'color' in 'chain'. Fails test if balance is not equal to expectedBalance.
+func (chainManager *ChainManager) requireAccountBalance(chain *solo.Chain, agentID *iscp.AgentID, color colored.Color, expectedBalance uint64) {
+	balance := chainManager.GetAgentBalance(agentID, chain, color)
+	require.EqualValues(chainManager.env.T, expectedBalance, balance, "Unexpected balance of %s in chain %s for color %s",
+		chainManager.nameOfAgentID(agentID), chain.Name, chainManager.keyPairManager.NameOfColor(color))
+}
+
+// nameOfAgentID names 'agentID' as a key pair (see KeyPairManager.NameOfAgentID), as a chain known to the manager or as one of its contracts
+func (chainManager *ChainManager) nameOfAgentID(agentID *iscp.AgentID) string {
+	for chainName, knownChain := range chainManager.chains {
+		if !agentID.Address().Equals(knownChain.ChainID.AsAddress()) {
+			continue
+		}
+		if agentID.Hname() == 0 {
+			return "chain " + chainName
+		}
+		_, _, contracts := knownChain.GetInfo()
+		if contractRecord, ok := contracts[agentID.Hname()]; ok {
+			return "contract " + contractRecord.Name + " of chain " + chainName
+		}
+	}
+	return chainManager.keyPairManager.NameOfAgentID(agentID)
+}
+
+func isEmpty(balances colored.Balances) bool {
+	return len(nonZero(balances)) == 0
+}
+
+// nonZero returns the balances which are not zero
+func nonZero(balances colored.Balances) colored.Balances {
+	nonZeroBalances := colored.Balances{}
+	for color, balance := range balances {
+		if balance > 0 {
+			nonZeroBalances[color] = balance
+		}
+	}
+	return nonZeroBalances
 }
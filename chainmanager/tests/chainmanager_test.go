@@ -4,8 +4,11 @@ import (
 	"testing"
 
 	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/brunoamancio/NotSolo/chainmanager"
 	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
 	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/vm/core/accounts"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_TransferToChainToSelf(t *testing.T) {
@@ -98,3 +101,166 @@ func Test_TransferWithinChain(t *testing.T) {
 	notSolo.Chain.RequireBalance(senderKeyPair, chain, colored.IOTA, 0)
 	notSolo.Chain.RequireBalance(receiverKeyPair, chain, colored.IOTA, senderBalanceInChain)
 }
+
+func Test_GetChainBalance(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+
+	// Create a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	transferAmount := uint64(100)
+
+	// Send some funds to chain
+	notSolo.L1.MustTransferToChainToSelf(senderKeyPair, chain, colored.IOTA, transferAmount)
+	require.Equal(t, utxodb.RequestFundsAmount-transferAmount, notSolo.L1.GetL1Balance(senderKeyPair, colored.IOTA))
+	require.Equal(t, colored.Balances{colored.IOTA: utxodb.RequestFundsAmount - transferAmount}, notSolo.L1.GetL1Balances(senderKeyPair))
+	require.Equal(t, transferAmount, notSolo.Chain.GetChainBalance(senderKeyPair, chain, colored.IOTA))
+	require.Equal(t, colored.Balances{colored.IOTA: transferAmount}, notSolo.Chain.GetChainBalances(senderKeyPair, chain))
+}
+
+func Test_RequireChainBalance(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with colored tokens
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	chainAgentID := notSolo.KeyPair.NamedChain("myChain", chain)
+	senderKeyPair, colors := notSolo.KeyPair.NewKeyPairWithBalance(utxodb.RequestFundsAmount, 1000)
+	transferAmount := uint64(100)
+	chainIotaBalance := notSolo.Chain.GetAgentBalance(chainAgentID, chain, colored.IOTA)
+
+	// Send colored tokens to the chain's own account
+	notSolo.L1.MustTransferToAgent(senderKeyPair, chain, colors[0], transferAmount, chainAgentID)
+	notSolo.Chain.RequireChainBalance(chain, colors[0], transferAmount)
+	notSolo.Chain.RequireChainBalances(chain, colored.Balances{colored.IOTA: chainIotaBalance, colors[0]: transferAmount})
+}
+
+func Test_Harvest(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with colored tokens
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	chainAgentID := notSolo.KeyPair.NamedChain("myChain", chain)
+	senderKeyPair, colors := notSolo.KeyPair.NewKeyPairWithBalance(utxodb.RequestFundsAmount, 1000)
+	transferAmount := uint64(100)
+
+	// Send colored tokens to the chain's own account
+	notSolo.L1.MustTransferToAgent(senderKeyPair, chain, colors[0], transferAmount, chainAgentID)
+	notSolo.Chain.RequireChainBalance(chain, colors[0], transferAmount)
+
+	// Harvest the colored tokens to the account of the chain owner
+	notSolo.Chain.Harvest(chain, colors[0], transferAmount)
+	notSolo.Chain.RequireChainBalance(chain, colors[0], 0)
+	notSolo.Chain.RequireBalance(chain.OriginatorKeyPair, chain, colors[0], transferAmount)
+}
+
+func Test_TransferToL1(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+
+	// Create a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	receiverKeyPair := notSolo.KeyPair.NewKeyPair()
+	depositAmount := uint64(100)
+	transferAmount := uint64(40)
+
+	// Send some funds to chain
+	notSolo.L1.MustTransferToChainToSelf(senderKeyPair, chain, colored.IOTA, depositAmount)
+
+	// Send part of the funds from chain to the receiver's address in L1
+	receiverAddress := notSolo.KeyPair.MustGetAddress(receiverKeyPair)
+	notSolo.Chain.MustTransferToL1(senderKeyPair, chain, colored.IOTA, transferAmount, receiverAddress)
+	notSolo.Chain.RequireBalance(senderKeyPair, chain, colored.IOTA, depositAmount-transferAmount)
+	notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, utxodb.RequestFundsAmount-depositAmount)
+	notSolo.L1.RequireBalance(receiverKeyPair, colored.IOTA, transferAmount)
+
+	// Try to withdraw more than what is left in the chain
+	err := notSolo.Chain.TransferToL1ToSelf(senderKeyPair, chain, colored.IOTA, depositAmount)
+	require.ErrorIs(t, err, chainmanager.ErrInsufficientFunds)
+}
+
+func Test_TransferToL1_WithFees(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain whose accounts contract charges an owner fee
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	const fee = uint64(10)
+	notSolo.Chain.ChangeContractFees(nil, chain, accounts.Contract.Name, fee)
+
+	// Create a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount) and send some funds to chain
+	senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	receiverKeyPair := notSolo.KeyPair.NewKeyPair()
+	notSolo.L1.MustTransferToChainToSelf(senderKeyPair, chain, colored.IOTA, 100)
+	balanceInL1 := notSolo.L1.GetL1Balance(senderKeyPair, colored.IOTA)
+	balanceInChain := notSolo.Chain.GetChainBalance(senderKeyPair, chain, colored.IOTA)
+	transferAmount := uint64(20)
+
+	// Withdraw to self. The withdrawal and the deposit of the rest are paid from the account, not from L1.
+	notSolo.Chain.MustTransferToL1ToSelf(senderKeyPair, chain, colored.IOTA, transferAmount)
+	balanceInL1 += transferAmount
+	balanceInChain -= transferAmount + 2*fee
+	notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, balanceInL1)
+	notSolo.Chain.RequireBalance(senderKeyPair, chain, colored.IOTA, balanceInChain)
+
+	// Withdraw to another address. The sender's balance in L1 does not change.
+	notSolo.Chain.MustTransferToL1(senderKeyPair, chain, colored.IOTA, transferAmount, notSolo.KeyPair.MustGetAddress(receiverKeyPair))
+	balanceInChain -= transferAmount + 2*fee
+	notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, balanceInL1)
+	notSolo.L1.RequireBalance(receiverKeyPair, colored.IOTA, transferAmount)
+	notSolo.Chain.RequireBalance(senderKeyPair, chain, colored.IOTA, balanceInChain)
+
+	// The fees must be covered by the account
+	err := notSolo.Chain.TransferToL1ToSelf(senderKeyPair, chain, colored.IOTA, balanceInChain)
+	require.ErrorIs(t, err, chainmanager.ErrInsufficientFunds)
+}
+
+func Test_TransferToAgentWithinChain(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	contractAgentID := notSolo.Chain.MustGetAgentID(chain, "blob")
+
+	// Create a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	depositAmount := uint64(100)
+	transferAmount := uint64(40)
+
+	// Send some funds to chain
+	notSolo.L1.MustTransferToChainToSelf(senderKeyPair, chain, colored.IOTA, depositAmount)
+	contractBalance := notSolo.Chain.GetAgentBalance(contractAgentID, chain, colored.IOTA)
+
+	// Send part of the funds to a contract in the same chain, without touching L1
+	notSolo.Chain.MustTransferToAgentWithinChain(senderKeyPair, chain, colored.IOTA, transferAmount, contractAgentID)
+	notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, utxodb.RequestFundsAmount-depositAmount)
+	notSolo.Chain.RequireBalance(senderKeyPair, chain, colored.IOTA, depositAmount-transferAmount)
+	notSolo.Chain.RequireAgentBalance(contractAgentID, chain, colored.IOTA, contractBalance+transferAmount)
+}
+
+func Test_TransferBalancesBetweenChains(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create sourceChain and destinationChain
+	sourceChain := notSolo.Chain.NewChain(nil, "mySourceChain")
+	destinationChain := notSolo.Chain.NewChain(nil, "myDestinationChain")
+
+	// Create a key pair with IOTA and two colored tokens
+	senderKeyPair, colors := notSolo.KeyPair.NewKeyPairWithBalance(utxodb.RequestFundsAmount, 1000, 2000)
+	receiverKeyPair := notSolo.KeyPair.NewKeyPair()
+	transfers := colored.Balances{colored.IOTA: 100, colors[0]: 10, colors[1]: 20}
+
+	// Send all colors to sourceChain with one request
+	notSolo.L1.MustTransferBalancesToChain(senderKeyPair, sourceChain, transfers, nil)
+	notSolo.Chain.RequireBalance(senderKeyPair, sourceChain, colors[0], 10)
+	notSolo.Chain.RequireBalance(senderKeyPair, sourceChain, colors[1], 20)
+
+	// Send part of them from sourceChain to destinationChain
+	notSolo.Chain.MustTransferBalancesBetweenChains(senderKeyPair, sourceChain, colored.Balances{colors[0]: 4, colors[1]: 5}, destinationChain, receiverKeyPair)
+	notSolo.Chain.RequireBalance(senderKeyPair, sourceChain, colors[0], 6)
+	notSolo.Chain.RequireBalance(senderKeyPair, sourceChain, colors[1], 15)
+	notSolo.Chain.RequireBalance(receiverKeyPair, destinationChain, colors[0], 4)
+	notSolo.Chain.RequireBalance(receiverKeyPair, destinationChain, colors[1], 5)
+}
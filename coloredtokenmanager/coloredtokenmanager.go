@@ -1,6 +1,9 @@
 package coloredtokenmanager
 
 import (
+	"github.com/brunoamancio/NotSolo/keypairmanager"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
 	"github.com/iotaledger/hive.go/crypto/ed25519"
 	"github.com/iotaledger/wasp/packages/iscp/colored"
 	"github.com/iotaledger/wasp/packages/solo"
@@ -9,18 +12,42 @@ import (
 
 // ColoredTokenManager manipulates colored tokens
 type ColoredTokenManager struct {
-	env *solo.Solo
+	env            *solo.Solo
+	keyPairManager *keypairmanager.KeyPairManager
+	getAddresses   func() []ledgerstate.Address
+	tokens         map[colored.Color]*Token
 }
 
-// New instantiates a colored token manager
-func New(env *solo.Solo) *ColoredTokenManager {
-	coloredTokenManager := &ColoredTokenManager{env: env}
+// Metadata describes a colored token. All fields are optional.
+type Metadata struct {
+	Symbol   string
+	Name     string
+	Decimals uint8
+}
+
+// Token is a color minted by ColoredTokenManager
+type Token struct {
+	Metadata
+	Color         colored.Color
+	Minter        *ed25519.KeyPair
+	InitialSupply uint64
+}
+
+// New instantiates a colored token manager. 'getAddresses' returns all L1 addresses which can hold tokens, including the addresses of chains
+// and of the requesters and receivers of requests.
+func New(env *solo.Solo, keyPairManager *keypairmanager.KeyPairManager, getAddresses func() []ledgerstate.Address) *ColoredTokenManager {
+	coloredTokenManager := &ColoredTokenManager{env: env, keyPairManager: keyPairManager, getAddresses: getAddresses, tokens: make(map[colored.Color]*Token)}
 	return coloredTokenManager
 }
 
+// Dispose implements Disposable for ColoredTokenManager. Forgets the minted tokens.
+func (coloredTokenmanager *ColoredTokenManager) Dispose() {
+	coloredTokenmanager.tokens = make(map[colored.Color]*Token)
+}
+
 // MintColoredTokens converts a specified amount of balance of iota tokens available to ed25519.KeyPair into a new color. Returns error if it fails.
 func (coloredTokenmanager *ColoredTokenManager) MintColoredTokens(keyPair *ed25519.KeyPair, amount uint64) (colored.Color, error) {
-	return coloredTokenmanager.env.MintTokens(keyPair, amount)
+	return coloredTokenmanager.MintColoredTokensWithMetadata(keyPair, amount, Metadata{})
 }
 
 // MustMintColoredTokens converts a specified amount of balance of iota tokens available to ed25519.KeyPair into a new color. Fails test on error.
@@ -29,3 +56,67 @@ func (coloredTokenmanager *ColoredTokenManager) MustMintColoredTokens(keyPair *e
 	require.NoError(coloredTokenmanager.env.T, err)
 	return color
 }
+
+// MintColoredTokensWithMetadata converts a specified amount of balance of iota tokens available to ed25519.KeyPair into a new color described
+// by 'metadata'. The color is named by its symbol in messages, if defined. Returns error if it fails.
+func (coloredTokenmanager *ColoredTokenManager) MintColoredTokensWithMetadata(keyPair *ed25519.KeyPair, amount uint64, metadata Metadata) (colored.Color, error) {
+	color, err := coloredTokenmanager.env.MintTokens(keyPair, amount)
+	if err != nil {
+		return color, err
+	}
+
+	coloredTokenmanager.tokens[color] = &Token{Metadata: metadata, Color: color, Minter: keyPair, InitialSupply: amount}
+	if metadata.Symbol != "" {
+		coloredTokenmanager.keyPairManager.NamedColor(metadata.Symbol, color)
+	}
+	return color, nil
+}
+
+// MustMintColoredTokensWithMetadata converts a specified amount of balance of iota tokens available to ed25519.KeyPair into a new color
+// described by 'metadata'. The color is named by its symbol in messages, if defined. Fails test on error.
+func (coloredTokenmanager *ColoredTokenManager) MustMintColoredTokensWithMetadata(keyPair *ed25519.KeyPair, amount uint64, metadata Metadata) colored.Color {
+	color, err := coloredTokenmanager.MintColoredTokensWithMetadata(keyPair, amount, metadata)
+	require.NoError(coloredTokenmanager.env.T, err)
+	return color
+}
+
+// GetToken returns the token of 'color' if it was minted by the manager, including colors minted by KeyPairManager.NewKeyPairWithBalance
+func (coloredTokenmanager *ColoredTokenManager) GetToken(color colored.Color) (*Token, bool) {
+	token, ok := coloredTokenmanager.tokens[color]
+	return token, ok
+}
+
+// TotalSupply returns the balance of 'color' summed over the addresses returned by 'getAddresses' (see New). Tokens which contracts or
+// direct calls to solo send to other addresses are not counted.
+func (coloredTokenmanager *ColoredTokenManager) TotalSupply(color colored.Color) uint64 {
+	return l1ledger.GetTotalBalances(coloredTokenmanager.env, coloredTokenmanager.getAddresses())[color]
+}
+
+// RequireSupply verifies if the total supply of 'color' in L1 and all chains is equal to 'expectedSupply'.
+// Fails test if supply is not equal to expectedSupply.
+func (coloredTokenmanager *ColoredTokenManager) RequireSupply(color colored.Color, expectedSupply uint64) {
+	supply := coloredTokenmanager.TotalSupply(color)
+	require.EqualValues(coloredTokenmanager.env.T, expectedSupply, supply, "Unexpected supply of color %s", coloredTokenmanager.keyPairManager.NameOfColor(color))
+}
+
+// DestroyColoredTokens converts a specified amount of balance of 'color' available to ed25519.KeyPair back into iota tokens. Returns error if it fails.
+func (coloredTokenmanager *ColoredTokenManager) DestroyColoredTokens(keyPair *ed25519.KeyPair, color colored.Color, amount uint64) error {
+	return l1ledger.Uncolor(coloredTokenmanager.env, keyPair, color, amount)
+}
+
+// MustDestroyColoredTokens converts a specified amount of balance of 'color' available to ed25519.KeyPair back into iota tokens.
+// Fails test on error or if the balances of 'color' and of iota tokens did not change by 'amount'.
+func (coloredTokenmanager *ColoredTokenManager) MustDestroyColoredTokens(keyPair *ed25519.KeyPair, color colored.Color, amount uint64) {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	colorBalance := coloredTokenmanager.env.GetAddressBalance(address, color)
+	iotaBalance := coloredTokenmanager.env.GetAddressBalance(address, colored.IOTA)
+
+	err := coloredTokenmanager.DestroyColoredTokens(keyPair, color, amount)
+	require.NoError(coloredTokenmanager.env.T, err)
+
+	colorName := coloredTokenmanager.keyPairManager.NameOfColor(color)
+	require.EqualValues(coloredTokenmanager.env.T, colorBalance-amount, coloredTokenmanager.env.GetAddressBalance(address, color),
+		"Unexpected balance of color %s after destroying tokens", colorName)
+	require.EqualValues(coloredTokenmanager.env.T, iotaBalance+amount, coloredTokenmanager.env.GetAddressBalance(address, colored.IOTA),
+		"Unexpected balance of IOTA after destroying tokens of color %s", colorName)
+}
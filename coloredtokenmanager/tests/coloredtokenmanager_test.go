@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"testing"
+
+	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/brunoamancio/NotSolo/coloredtokenmanager"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/vm/core/accounts"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DestroyColoredTokens(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount) and mint some colored tokens
+	keyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	mintAmount := uint64(100)
+	destroyAmount := uint64(40)
+	color := notSolo.ColoredToken.MustMintColoredTokens(keyPair, mintAmount)
+	notSolo.L1.RequireBalance(keyPair, color, mintAmount)
+
+	// Destroy part of the colored tokens
+	notSolo.ColoredToken.MustDestroyColoredTokens(keyPair, color, destroyAmount)
+	notSolo.L1.RequireBalance(keyPair, color, mintAmount-destroyAmount)
+	notSolo.L1.RequireBalance(keyPair, colored.IOTA, utxodb.RequestFundsAmount-mintAmount+destroyAmount)
+
+	// Destroying more than the balance fails
+	err := notSolo.ColoredToken.DestroyColoredTokens(keyPair, color, mintAmount)
+	require.ErrorIs(t, err, l1ledger.ErrInsufficientFunds)
+
+	// Destroying nothing fails without adding a transaction
+	err = notSolo.ColoredToken.DestroyColoredTokens(keyPair, color, 0)
+	require.ErrorIs(t, err, l1ledger.ErrZeroAmount)
+	notSolo.L1.RequireBalance(keyPair, color, mintAmount-destroyAmount)
+}
+
+func Test_TotalSupply(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Mint a token with metadata
+	minterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	supply := uint64(1000)
+	metadata := coloredtokenmanager.Metadata{Symbol: "USDX", Name: "US Dollar X", Decimals: 2}
+	color := notSolo.ColoredToken.MustMintColoredTokensWithMetadata(minterKeyPair, supply, metadata)
+
+	token, ok := notSolo.ColoredToken.GetToken(color)
+	require.True(t, ok)
+	require.Equal(t, metadata, token.Metadata)
+	require.Equal(t, supply, token.InitialSupply)
+	require.Equal(t, minterKeyPair, token.Minter)
+	require.Equal(t, "USDX", notSolo.KeyPair.NameOfColor(color))
+
+	// Moving tokens to a chain does not change the supply, destroying them does
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	notSolo.L1.MustTransferToChainToSelf(minterKeyPair, chain, color, 100)
+	notSolo.ColoredToken.RequireSupply(color, supply)
+	notSolo.ColoredToken.MustDestroyColoredTokens(minterKeyPair, color, 100)
+	notSolo.ColoredToken.RequireSupply(color, supply-100)
+}
+
+func Test_TotalSupply_ExternalAddress(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Mint a token and send part of it to an address which is not generated by the key pair manager
+	minterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	supply := uint64(1000)
+	color := notSolo.ColoredToken.MustMintColoredTokens(minterKeyPair, supply)
+	externalKeyPair := ed25519.GenerateKeyPair()
+	externalAddress := ledgerstate.NewED25519Address(externalKeyPair.PublicKey)
+	notSolo.L1.MustTransferL1(minterKeyPair, externalAddress, color, 100)
+
+	// The tokens of the external address are part of the supply
+	notSolo.L1.RequireAddressBalance(externalAddress, color, 100)
+	notSolo.ColoredToken.RequireSupply(color, supply)
+}
+
+func Test_TotalSupply_WithdrawnToExternalAddress(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Mint a token and send part of it to the account of an agent which is not generated by the key pair manager
+	minterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	supply := uint64(1000)
+	color := notSolo.ColoredToken.MustMintColoredTokens(minterKeyPair, supply)
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	notSolo.L1.MustTransferToChainToSelf(minterKeyPair, chain, color, 100)
+	notSolo.L1.MustTransferToChainToSelf(minterKeyPair, chain, colored.IOTA, 10)
+	externalKeyPair := ed25519.GenerateKeyPair()
+	externalAddress := ledgerstate.NewED25519Address(externalKeyPair.PublicKey)
+	notSolo.Chain.MustTransferToAgentWithinChain(minterKeyPair, chain, color, 100, iscp.NewAgentID(externalAddress, 0))
+	notSolo.Chain.MustTransferToAgentWithinChain(minterKeyPair, chain, colored.IOTA, 10, iscp.NewAgentID(externalAddress, 0))
+
+	// The external agent withdraws to its address in L1, which the chain sends the tokens to
+	notSolo.Request.MustPostOffLedger(&externalKeyPair, chain, accounts.Contract.Name, accounts.FuncWithdraw.Name)
+
+	// The tokens of the external address are part of the supply
+	notSolo.L1.RequireAddressBalance(externalAddress, color, 100)
+	notSolo.ColoredToken.RequireSupply(color, supply)
+}
+
+func Test_NewKeyPairWithBalance_RegistersColors(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a key pair with colored tokens
+	mintAmount := uint64(500)
+	keyPair, colors := notSolo.KeyPair.NewKeyPairWithBalance(utxodb.RequestFundsAmount, mintAmount)
+
+	// The colors are registered as minted by the key pair
+	token, ok := notSolo.ColoredToken.GetToken(colors[0])
+	require.True(t, ok)
+	require.Equal(t, keyPair, token.Minter)
+	require.Equal(t, mintAmount, token.InitialSupply)
+	notSolo.ColoredToken.RequireSupply(colors[0], mintAmount)
+}
@@ -0,0 +1,122 @@
+package conservationchecker
+
+import (
+	"fmt"
+
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// ConservationChecker implements RequestObserver. It verifies that requests neither create nor destroy tokens, by summing every color
+// over the L1 addresses which can hold tokens before and after each request, and that the accounts of the chain add up to its total assets.
+type ConservationChecker struct {
+	env             *solo.Solo
+	getAddresses    func() []ledgerstate.Address
+	addressesBefore []ledgerstate.Address
+	balancesBefore  colored.Balances
+	isBeforeRequest bool
+}
+
+// New instantiates a conservation checker. 'getAddresses' returns all L1 addresses which can hold tokens moved by requests.
+func New(env *solo.Solo, getAddresses func() []ledgerstate.Address) *ConservationChecker {
+	conservationChecker := &ConservationChecker{env: env, getAddresses: getAddresses}
+	return conservationChecker
+}
+
+// BeforeRequest implements RequestObserver for ConservationChecker. Records the total balances of the known addresses and of 'addresses'.
+func (conservationChecker *ConservationChecker) BeforeRequest(chain *solo.Chain, addresses []ledgerstate.Address) {
+	conservationChecker.addressesBefore = append(conservationChecker.getAddresses(), addresses...)
+	conservationChecker.balancesBefore = l1ledger.GetTotalBalances(conservationChecker.env, conservationChecker.addressesBefore)
+	conservationChecker.isBeforeRequest = true
+}
+
+// AfterRequest implements RequestObserver for ConservationChecker. Fails test if tokens were not conserved (see Compare) or, unless the
+// request was a transfer in L1, if the accounts of 'chain' do not add up to its total assets (see CheckChain).
+func (conservationChecker *ConservationChecker) AfterRequest(chain *solo.Chain) {
+	if !conservationChecker.isBeforeRequest {
+		return
+	}
+	conservationChecker.isBeforeRequest = false
+
+	description := "transfer in L1"
+	if chain != nil {
+		description = "request to chain " + chain.Name
+		err := CheckChain(conservationChecker.env, chain)
+		require.NoError(conservationChecker.env.T, err, "Accounts of chain %s are inconsistent after %s", chain.Name, description)
+	}
+
+	balancesAfter := l1ledger.GetTotalBalances(conservationChecker.env, conservationChecker.addressesBefore)
+	err := Compare(conservationChecker.balancesBefore, balancesAfter)
+	require.NoError(conservationChecker.env.T, err, "Tokens are not conserved by %s", description)
+}
+
+// CheckChain verifies that the accounts of 'chain' add up to its total assets and that its address in L1 holds them.
+// Returns an error otherwise.
+func CheckChain(env *solo.Solo, chain *solo.Chain) error {
+	accountTotals := colored.Balances{}
+	for _, agentID := range chain.GetAccounts() {
+		agentID := agentID
+		for color, balance := range chain.GetAccountBalance(&agentID) {
+			accountTotals[color] += balance
+		}
+	}
+
+	totalAssets := chain.GetTotalAssets()
+	for color := range mergeColors(accountTotals, totalAssets) {
+		if accountTotals[color] != totalAssets[color] {
+			return fmt.Errorf("accounts hold %d of color %s, but the total assets are %d", accountTotals[color], color, totalAssets[color])
+		}
+	}
+
+	l1Balances := env.GetAddressBalances(chain.ChainID.AsAddress())
+	for color, balance := range totalAssets {
+		if l1Balances[color] < balance {
+			return fmt.Errorf("total assets hold %d of color %s, but the chain holds %d in L1", balance, color, l1Balances[color])
+		}
+	}
+	return nil
+}
+
+// Compare verifies that the total balances 'after' a request could result from the total balances 'before' it. Returns an error if the
+// total balance of all colors changed or if the balance of an existing color other than IOTA increased.
+func Compare(before colored.Balances, after colored.Balances) error {
+	if sum(before) != sum(after) {
+		return fmt.Errorf("tokens were created or destroyed. Before: %s. After: %s", before, after)
+	}
+
+	// Minting creates new colors out of IOTA, so existing colors other than IOTA may only decrease
+	for color, balanceBefore := range before {
+		if color != colored.IOTA && after[color] > balanceBefore {
+			return fmt.Errorf("tokens of color %s were created. Before: %d. After: %d", color, balanceBefore, after[color])
+		}
+	}
+	return nil
+}
+
+// GetTotalBalances sums the L1 balances of all known addresses by color
+func (conservationChecker *ConservationChecker) GetTotalBalances() colored.Balances {
+	return l1ledger.GetTotalBalances(conservationChecker.env, conservationChecker.getAddresses())
+}
+
+func sum(balances colored.Balances) uint64 {
+	total := uint64(0)
+	for _, balance := range balances {
+		total += balance
+	}
+	return total
+}
+
+// mergeColors returns the colors of both 'balances' and 'otherBalances'
+func mergeColors(balances colored.Balances, otherBalances colored.Balances) map[colored.Color]bool {
+	colors := make(map[colored.Color]bool, len(balances)+len(otherBalances))
+	for color := range balances {
+		colors[color] = true
+	}
+	for color := range otherBalances {
+		colors[color] = true
+	}
+	return colors
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/brunoamancio/NotSolo/conservationchecker"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/iotaledger/wasp/packages/vm/core/accounts"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Compare(t *testing.T) {
+	existingColor := colored.Color{1}
+	mintedColor := colored.Color{2}
+	before := colored.Balances{colored.IOTA: 1000, existingColor: 100}
+
+	// Minting turns IOTA into a new color and burning turns a color back into IOTA
+	require.NoError(t, conservationchecker.Compare(before, colored.Balances{colored.IOTA: 900, existingColor: 100, mintedColor: 100}))
+	require.NoError(t, conservationchecker.Compare(before, colored.Balances{colored.IOTA: 1100}))
+
+	// Tokens appear or disappear
+	require.Error(t, conservationchecker.Compare(before, colored.Balances{colored.IOTA: 1001, existingColor: 100}))
+	require.Error(t, conservationchecker.Compare(before, colored.Balances{colored.IOTA: 1000, existingColor: 99}))
+
+	// An existing color is created out of IOTA
+	require.Error(t, conservationchecker.Compare(before, colored.Balances{colored.IOTA: 900, existingColor: 200}))
+}
+
+func Test_ConservationChecker_Request(t *testing.T) {
+	env := solo.New(t, false, false)
+	chain := env.NewChain(nil, "myChain")
+	keyPair, address := env.NewKeyPairWithFunds()
+	getAddresses := func() []ledgerstate.Address {
+		return []ledgerstate.Address{address, chain.ChainID.AsAddress()}
+	}
+	conservationChecker := conservationchecker.New(env, getAddresses)
+	totalBalances := conservationChecker.GetTotalBalances()
+
+	// A deposit moves tokens from the key pair to the chain, so the total balances do not change
+	conservationChecker.BeforeRequest(chain, nil)
+	_, err := chain.PostRequestSync(solo.NewCallParams(accounts.Contract.Name, accounts.FuncDeposit.Name).WithIotas(100), keyPair)
+	require.NoError(t, err)
+	conservationChecker.AfterRequest(chain)
+
+	require.Equal(t, totalBalances, conservationChecker.GetTotalBalances())
+	require.NoError(t, conservationchecker.CheckChain(env, chain))
+}
+
+func Test_ConservationChecker_Receiver(t *testing.T) {
+	env := solo.New(t, false, false)
+	keyPair, address := env.NewKeyPairWithFunds()
+	_, receiverAddress := env.NewKeyPair()
+	getAddresses := func() []ledgerstate.Address {
+		return []ledgerstate.Address{address}
+	}
+	conservationChecker := conservationchecker.New(env, getAddresses)
+	totalBalances := conservationChecker.GetTotalBalances()
+
+	// The receiver of a transfer is counted while the transfer is checked, although it is not a known address
+	conservationChecker.BeforeRequest(nil, []ledgerstate.Address{receiverAddress})
+	require.NoError(t, l1ledger.Transfer(env, keyPair, receiverAddress, colored.Balances{colored.IOTA: 100}))
+	conservationChecker.AfterRequest(nil)
+
+	// Without the receiver, the tokens look destroyed
+	require.Error(t, conservationchecker.Compare(totalBalances, conservationChecker.GetTotalBalances()))
+}
@@ -0,0 +1,67 @@
+package keypairmanager
+
+import (
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// NamedAgent gives 'name' to 'agentID', which may belong to a contract, to another chain or to an alias address.
+// Named agents are named in messages like named key pairs. Fails test if 'name' is already in use.
+// Multi-signature identities are not supported: the only such address of goshimmer is the BLS threshold address, which solo cannot sign for.
+func (keyPairHandler *KeyPairManager) NamedAgent(name string, agentID *iscp.AgentID) *iscp.AgentID {
+	_, isKeyPairName := keyPairHandler.names[name]
+	require.False(keyPairHandler.env.T, isKeyPairName, "Name %s is already in use", name)
+	keyPairHandler.requireNotAgentName(name)
+
+	keyPairHandler.agents[name] = agentID
+	return agentID
+}
+
+// NamedAlias returns the AgentID of the alias address called 'name'. The alias address is derived from the master seed the first time 'name'
+// is used. Alias addresses are owned by chains, so no key pair can sign for them.
+func (keyPairHandler *KeyPairManager) NamedAlias(name string) *iscp.AgentID {
+	if agentID, ok := keyPairHandler.agents[name]; ok {
+		return agentID
+	}
+	aliasAddress := ledgerstate.NewAliasAddress(keyPairHandler.nextSeed().Bytes())
+	return keyPairHandler.NamedAgent(name, iscp.NewAgentID(aliasAddress, 0))
+}
+
+// NamedChain returns the AgentID of 'chain' itself, called 'name'. Deposits from other chains to a chain are credited to this AgentID.
+func (keyPairHandler *KeyPairManager) NamedChain(name string, chain *solo.Chain) *iscp.AgentID {
+	if agentID, ok := keyPairHandler.agents[name]; ok {
+		return agentID
+	}
+	return keyPairHandler.NamedAgent(name, iscp.NewAgentID(chain.ChainID.AsAddress(), 0))
+}
+
+// NamedContract returns the AgentID of 'contract' in 'chain', called 'name'. Deposits from contracts are credited to this AgentID.
+func (keyPairHandler *KeyPairManager) NamedContract(name string, chain *solo.Chain, contractName string) *iscp.AgentID {
+	if agentID, ok := keyPairHandler.agents[name]; ok {
+		return agentID
+	}
+	return keyPairHandler.NamedAgent(name, iscp.NewAgentID(chain.ChainID.AsAddress(), iscp.Hn(contractName)))
+}
+
+// GetAgentByName returns the agent called 'name', if any
+func (keyPairHandler *KeyPairManager) GetAgentByName(name string) (*iscp.AgentID, bool) {
+	agentID, ok := keyPairHandler.agents[name]
+	return agentID, ok
+}
+
+// requireNotAgentName fails test if 'name' is given to an agent
+func (keyPairHandler *KeyPairManager) requireNotAgentName(name string) {
+	_, isAgentName := keyPairHandler.agents[name]
+	require.False(keyPairHandler.env.T, isAgentName, "Name %s is already in use", name)
+}
+
+func (keyPairHandler *KeyPairManager) getAgentName(agentID *iscp.AgentID) (string, bool) {
+	for name, namedAgentID := range keyPairHandler.agents {
+		if namedAgentID.Equals(agentID) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,18 @@
+package keypairmanager
+
+import (
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+)
+
+// NamedColor gives 'name' (e.g. a token symbol) to 'color'. Named colors are shown by their name in messages.
+func (keyPairHandler *KeyPairManager) NamedColor(name string, color colored.Color) {
+	keyPairHandler.colorNames[color] = name
+}
+
+// NameOfColor returns the name of 'color' or, if it is not named, its base58 representation
+func (keyPairHandler *KeyPairManager) NameOfColor(color colored.Color) string {
+	if name, ok := keyPairHandler.colorNames[color]; ok {
+		return name
+	}
+	return color.String()
+}
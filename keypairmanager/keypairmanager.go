@@ -1,48 +1,253 @@
 package keypairmanager
 
 import (
+	"encoding/binary"
+
+	"github.com/brunoamancio/NotSolo/l1ledger"
 	"github.com/iotaledger/goshimmer/packages/ledgerstate"
 	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
 	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/hashing"
 	"github.com/iotaledger/wasp/packages/iscp"
 	"github.com/iotaledger/wasp/packages/iscp/colored"
 	"github.com/iotaledger/wasp/packages/solo"
 	"github.com/stretchr/testify/require"
 )
 
-// KeyPairManager manipulates signature structures
+// KeyPairManager manipulates signature structures. The other managers name key pairs, agents and colors in their messages through it.
+// AgentIDs given to the managers may belong to a key pair, a contract, another chain or an alias address (see NamedAgent).
 type KeyPairManager struct {
-	env *solo.Solo
+	env        *solo.Solo
+	masterSeed *ed25519.Seed
+	nextIndex  uint64
+	minter     Minter
+	keyPairs   []*ed25519.KeyPair
+	names      map[string]*ed25519.KeyPair
+	agents     map[string]*iscp.AgentID
+	colorNames map[colored.Color]string
 }
 
-// New instantiates a key pair manager
-func New(env *solo.Solo) *KeyPairManager {
-	keyPairHandler := &KeyPairManager{env: env}
+// Minter converts 'amount' iota tokens of 'keyPair' in L1 into a new color
+type Minter func(keyPair *ed25519.KeyPair, amount uint64) (colored.Color, error)
+
+// New instantiates a key pair manager. Key pairs generated without a seed are derived from 'masterSeed', so they are the same in every run with the same master seed.
+// Colors are minted by solo until another minter is set with SetMinter.
+func New(env *solo.Solo, masterSeed *ed25519.Seed) *KeyPairManager {
+	keyPairHandler := &KeyPairManager{env: env, masterSeed: masterSeed, minter: env.MintTokens, names: make(map[string]*ed25519.KeyPair),
+		agents: make(map[string]*iscp.AgentID), colorNames: make(map[colored.Color]string)}
 	return keyPairHandler
 }
 
-// NewKeyPair generates a private/public key pair. Fails test on error.
+// SetMinter defines how NewKeyPairWithBalance mints colors, e.g. through a manager which registers them
+func (keyPairHandler *KeyPairManager) SetMinter(minter Minter) {
+	keyPairHandler.minter = minter
+}
+
+// GetMasterSeed returns the seed from which key pairs are derived
+func (keyPairHandler *KeyPairManager) GetMasterSeed() *ed25519.Seed {
+	return keyPairHandler.masterSeed
+}
+
+// Dispose implements Disposable for KeyPairManager. The index of the next derived key pair is kept, since the key pairs derived so far
+// still exist in L1.
+func (keyPairHandler *KeyPairManager) Dispose() {
+	keyPairHandler.keyPairs = nil
+	keyPairHandler.names = make(map[string]*ed25519.KeyPair)
+	keyPairHandler.agents = make(map[string]*iscp.AgentID)
+	keyPairHandler.colorNames = make(map[colored.Color]string)
+}
+
+// GetKeyPairs returns all key pairs generated by the manager
+func (keyPairHandler *KeyPairManager) GetKeyPairs() []*ed25519.KeyPair {
+	return append([]*ed25519.KeyPair(nil), keyPairHandler.keyPairs...)
+}
+
+// NewKeyPair generates a private/public key pair from 'seed' or, if not specified, from the master seed. Fails test on error.
 func (keyPairHandler *KeyPairManager) NewKeyPair(seed ...*ed25519.Seed) *ed25519.KeyPair {
-	keyPair, address := keyPairHandler.env.NewKeyPair(seed...)
+	keyPair, address := keyPairHandler.env.NewKeyPair(keyPairHandler.seedOrNext(seed)...)
 	require.NotNil(keyPairHandler.env.T, keyPair)
 	require.NotNil(keyPairHandler.env.T, keyPair.PrivateKey)
 	require.NotNil(keyPairHandler.env.T, keyPair.PublicKey)
 	require.NotNil(keyPairHandler.env.T, address)
 	keyPairHandler.RequireL1Balance(keyPair, colored.IOTA, 0)
+	keyPairHandler.keyPairs = append(keyPairHandler.keyPairs, keyPair)
 	return keyPair
 }
 
-// NewKeyPairWithFunds generates a private/public key pair and assigns 1337 iota tokens to it (amount of funds is defined in utxodb.RequestFundsAmount)
+// NewKeyPairWithFunds generates a private/public key pair from 'seed' or, if not specified, from the master seed and assigns
+// utxodb.RequestFundsAmount (1Mi) iota tokens to it
 func (keyPairHandler *KeyPairManager) NewKeyPairWithFunds(seed ...*ed25519.Seed) *ed25519.KeyPair {
-	keyPair, address := keyPairHandler.env.NewKeyPairWithFunds(seed...)
+	keyPair, address := keyPairHandler.env.NewKeyPairWithFunds(keyPairHandler.seedOrNext(seed)...)
 	require.NotNil(keyPairHandler.env.T, keyPair)
 	require.NotNil(keyPairHandler.env.T, keyPair.PrivateKey)
 	require.NotNil(keyPairHandler.env.T, keyPair.PublicKey)
 	require.NotNil(keyPairHandler.env.T, address)
 	keyPairHandler.RequireL1Balance(keyPair, colored.IOTA, utxodb.RequestFundsAmount)
+	keyPairHandler.keyPairs = append(keyPairHandler.keyPairs, keyPair)
+	return keyPair
+}
+
+// NewKeyPairWithBalance generates a private/public key pair which holds 'iotaAmount' iota tokens in L1 and, for every amount in 'mintAmounts',
+// a new color with that balance. Returns the key pair and the minted colors, in the order of 'mintAmounts'. Fails test on error.
+func (keyPairHandler *KeyPairManager) NewKeyPairWithBalance(iotaAmount uint64, mintAmounts ...uint64) (*ed25519.KeyPair, []colored.Color) {
+	keyPair := keyPairHandler.NewKeyPair()
+
+	totalAmount := iotaAmount
+	for _, mintAmount := range mintAmounts {
+		totalAmount += mintAmount
+	}
+	keyPairHandler.fund(keyPair, totalAmount)
+
+	colors := make([]colored.Color, len(mintAmounts))
+	for i, mintAmount := range mintAmounts {
+		color, err := keyPairHandler.minter(keyPair, mintAmount)
+		require.NoError(keyPairHandler.env.T, err, "Could not mint colored tokens for %s", keyPairHandler.NameOf(keyPair))
+		colors[i] = color
+	}
+
+	keyPairHandler.RequireL1Balance(keyPair, colored.IOTA, iotaAmount)
+	for i, color := range colors {
+		keyPairHandler.RequireL1Balance(keyPair, color, mintAmounts[i])
+	}
+	return keyPair, colors
+}
+
+// fund transfers 'amount' iota tokens to the address of 'keyPair' in L1. Every faucet call of solo yields utxodb.RequestFundsAmount (1Mi)
+// to a new key pair, so ceil(amount / 1Mi) calls are made. Each one is transferred in full, except the last one, which transfers the rest of
+// 'amount' and leaves the change with its faucet key pair. The received outputs are consolidated so that the funds can be spent by a
+// single transaction.
+func (keyPairHandler *KeyPairManager) fund(keyPair *ed25519.KeyPair, amount uint64) {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	for amount > 0 {
+		transferAmount := amount
+		if transferAmount > utxodb.RequestFundsAmount {
+			transferAmount = utxodb.RequestFundsAmount
+		}
+
+		faucetKeyPair, _ := keyPairHandler.env.NewKeyPairWithFunds(keyPairHandler.nextSeed())
+		err := l1ledger.Transfer(keyPairHandler.env, faucetKeyPair, address, colored.Balances{colored.IOTA: transferAmount})
+		require.NoError(keyPairHandler.env.T, err, "Could not fund %s", keyPairHandler.NameOf(keyPair))
+		amount -= transferAmount
+
+		if len(l1ledger.GetOutputs(keyPairHandler.env, address)) >= ledgerstate.MaxInputCount {
+			keyPairHandler.consolidate(keyPair)
+		}
+	}
+	keyPairHandler.consolidate(keyPair)
+}
+
+// consolidate merges the L1 outputs of 'keyPair' into one. Fails test on error.
+func (keyPairHandler *KeyPairManager) consolidate(keyPair *ed25519.KeyPair) {
+	err := l1ledger.Consolidate(keyPairHandler.env, keyPair)
+	require.NoError(keyPairHandler.env.T, err, "Could not consolidate the outputs of %s", keyPairHandler.NameOf(keyPair))
+}
+
+// seedOrNext returns 'seed' if specified. Otherwise, the next seed derived from the master seed.
+func (keyPairHandler *KeyPairManager) seedOrNext(seed []*ed25519.Seed) []*ed25519.Seed {
+	if len(seed) > 0 && seed[0] != nil {
+		return seed
+	}
+	return []*ed25519.Seed{keyPairHandler.nextSeed()}
+}
+
+// nextSeed derives a seed from the master seed and the index of the next key pair
+func (keyPairHandler *KeyPairManager) nextSeed() *ed25519.Seed {
+	indexBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(indexBytes, keyPairHandler.nextIndex)
+	keyPairHandler.nextIndex++
+
+	seedHash := hashing.HashData(keyPairHandler.masterSeed.Bytes(), indexBytes)
+	return ed25519.NewSeed(seedHash[:])
+}
+
+// Named returns the key pair called 'name'. The key pair is generated without funds the first time 'name' is used. Fails test on error or
+// if 'name' is given to an agent.
+func (keyPairHandler *KeyPairManager) Named(name string) *ed25519.KeyPair {
+	if keyPair, ok := keyPairHandler.names[name]; ok {
+		return keyPair
+	}
+	keyPairHandler.requireNotAgentName(name)
+	keyPair := keyPairHandler.NewKeyPair()
+	keyPairHandler.names[name] = keyPair
+	return keyPair
+}
+
+// NamedWithFunds returns the key pair called 'name'. The key pair is generated with funds (see NewKeyPairWithFunds) the first time 'name' is used.
+// Fails test on error or if 'name' is given to an agent.
+func (keyPairHandler *KeyPairManager) NamedWithFunds(name string) *ed25519.KeyPair {
+	if keyPair, ok := keyPairHandler.names[name]; ok {
+		return keyPair
+	}
+	keyPairHandler.requireNotAgentName(name)
+	keyPair := keyPairHandler.NewKeyPairWithFunds()
+	keyPairHandler.names[name] = keyPair
 	return keyPair
 }
 
+// GetByName returns the key pair called 'name', if any
+func (keyPairHandler *KeyPairManager) GetByName(name string) (*ed25519.KeyPair, bool) {
+	keyPair, ok := keyPairHandler.names[name]
+	return keyPair, ok
+}
+
+// GetByAddress returns the named key pair which owns 'address' in L1, if any
+func (keyPairHandler *KeyPairManager) GetByAddress(address ledgerstate.Address) (*ed25519.KeyPair, bool) {
+	name, ok := keyPairHandler.getName(address)
+	if !ok {
+		return nil, false
+	}
+	return keyPairHandler.GetByName(name)
+}
+
+// GetByAgentID returns the named key pair which owns 'agentID', if any
+func (keyPairHandler *KeyPairManager) GetByAgentID(agentID *iscp.AgentID) (*ed25519.KeyPair, bool) {
+	if agentID.Hname() != 0 {
+		return nil, false
+	}
+	return keyPairHandler.GetByAddress(agentID.Address())
+}
+
+// NameOf returns the name of 'keyPair' or, if it has no name, its address in base58. Returns "chain originator" if 'keyPair' is nil.
+func (keyPairHandler *KeyPairManager) NameOf(keyPair *ed25519.KeyPair) string {
+	if keyPair == nil {
+		return "chain originator"
+	}
+	return keyPairHandler.NameOfAddress(ledgerstate.NewED25519Address(keyPair.PublicKey))
+}
+
+// NameOfAddress returns the name of the key pair or agent which owns 'address' or, if it has no name, the address in base58
+func (keyPairHandler *KeyPairManager) NameOfAddress(address ledgerstate.Address) string {
+	if name, ok := keyPairHandler.getName(address); ok {
+		return name
+	}
+	if name, ok := keyPairHandler.getAgentName(iscp.NewAgentID(address, 0)); ok {
+		return name
+	}
+	return address.Base58()
+}
+
+// NameOfAgentID returns the name of the key pair or agent which owns 'agentID' or, if it has no name, the AgentID as string
+func (keyPairHandler *KeyPairManager) NameOfAgentID(agentID *iscp.AgentID) string {
+	if name, ok := keyPairHandler.getAgentName(agentID); ok {
+		return name
+	}
+	if agentID.Hname() == 0 {
+		if name, ok := keyPairHandler.getName(agentID.Address()); ok {
+			return name
+		}
+	}
+	return agentID.String()
+}
+
+func (keyPairHandler *KeyPairManager) getName(address ledgerstate.Address) (string, bool) {
+	for name, keyPair := range keyPairHandler.names {
+		if ledgerstate.NewED25519Address(keyPair.PublicKey).Equals(address) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // MustGetAgentID gets the AgentID corresponding to specified signatureScheme. Fails test on error.
 func (keyPairHandler *KeyPairManager) MustGetAgentID(keyPair *ed25519.KeyPair) iscp.AgentID {
 	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
@@ -61,6 +266,19 @@ func (keyPairHandler *KeyPairManager) MustGetAddress(keyPair *ed25519.KeyPair) l
 // RequireL1Balance verifies if the key pair has the expected balance of the specified color in L1.
 // Fails test if balance is not equal to expectedBalance.
 func (keyPairHandler *KeyPairManager) RequireL1Balance(keyPair *ed25519.KeyPair, color colored.Color, expectedBalance uint64) {
+	balance := keyPairHandler.GetL1Balance(keyPair, color)
+	require.EqualValues(keyPairHandler.env.T, expectedBalance, balance, "Unexpected balance of %s in L1 for color %s", keyPairHandler.NameOf(keyPair),
+		keyPairHandler.NameOfColor(color))
+}
+
+// GetL1Balance returns the balance of the specified color of the key pair in L1
+func (keyPairHandler *KeyPairManager) GetL1Balance(keyPair *ed25519.KeyPair, color colored.Color) uint64 {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	return keyPairHandler.env.GetAddressBalance(address, color)
+}
+
+// GetL1Balances returns the balances of all colors of the key pair in L1
+func (keyPairHandler *KeyPairManager) GetL1Balances(keyPair *ed25519.KeyPair) colored.Balances {
 	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
-	keyPairHandler.env.AssertAddressBalance(address, color, expectedBalance)
+	return keyPairHandler.env.GetAddressBalances(address)
 }
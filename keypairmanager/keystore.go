@@ -0,0 +1,155 @@
+package keypairmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// KeyStoreEntry is a key pair in a key store file. On import, either 'Seed' or 'PrivateKey' must be defined.
+// 'Address' and 'AgentID' are informative, but are verified on import if defined.
+type KeyStoreEntry struct {
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+	Seed       string `json:"seed,omitempty" yaml:"seed,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+	Address    string `json:"address,omitempty" yaml:"address,omitempty"`
+	AgentID    string `json:"agentID,omitempty" yaml:"agentID,omitempty"`
+}
+
+// Export writes all key pairs generated by the manager, with their names, to a key store file in 'path'.
+// The file is written in YAML if 'path' ends with ".yaml" or ".yml". Otherwise, in JSON.
+func (keyPairHandler *KeyPairManager) Export(path string) error {
+	entries := make([]KeyStoreEntry, len(keyPairHandler.keyPairs))
+	for i, keyPair := range keyPairHandler.keyPairs {
+		agentID := keyPairHandler.MustGetAgentID(keyPair)
+		entries[i] = KeyStoreEntry{
+			PrivateKey: base58.Encode(keyPair.PrivateKey.Bytes()),
+			Address:    keyPairHandler.MustGetAddress(keyPair).Base58(),
+			AgentID:    agentID.String(),
+		}
+		if name, ok := keyPairHandler.getName(ledgerstate.NewED25519Address(keyPair.PublicKey)); ok {
+			entries[i].Name = name
+		}
+	}
+
+	var data []byte
+	var err error
+	if isYaml(path) {
+		data, err = yaml.Marshal(entries)
+	} else {
+		data, err = json.MarshalIndent(entries, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// MustExport writes all key pairs generated by the manager to a key store file in 'path'. See Export. Fails test on error.
+func (keyPairHandler *KeyPairManager) MustExport(path string) {
+	err := keyPairHandler.Export(path)
+	require.NoError(keyPairHandler.env.T, err, "Could not export key pairs to %s", path)
+}
+
+// Import reads the key pairs in the key store file in 'path' and adds them to the manager. Entries with a name can be retrieved with Named.
+// The file is read as YAML if 'path' ends with ".yaml" or ".yml". Otherwise, as JSON. Returns the imported key pairs in the order of the file.
+// Every entry is validated before any is added, so nothing is imported if an entry is invalid or its name is in use.
+func (keyPairHandler *KeyPairManager) Import(path string) ([]*ed25519.KeyPair, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []KeyStoreEntry
+	if isYaml(path) {
+		err = yaml.Unmarshal(data, &entries)
+	} else {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyPairs := make([]*ed25519.KeyPair, len(entries))
+	namesInFile := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		if keyPairs[i], err = keyPairHandler.readEntry(entry, namesInFile); err != nil {
+			return nil, fmt.Errorf("entry %d of %s: %w", i, path, err)
+		}
+	}
+
+	for i, keyPair := range keyPairs {
+		keyPairHandler.keyPairs = append(keyPairHandler.keyPairs, keyPair)
+		if entries[i].Name != "" {
+			keyPairHandler.names[entries[i].Name] = keyPair
+		}
+	}
+	return keyPairs, nil
+}
+
+// MustImport reads the key pairs in the key store file in 'path' and adds them to the manager. See Import. Fails test on error.
+func (keyPairHandler *KeyPairManager) MustImport(path string) []*ed25519.KeyPair {
+	keyPairs, err := keyPairHandler.Import(path)
+	require.NoError(keyPairHandler.env.T, err, "Could not import key pairs from %s", path)
+	return keyPairs
+}
+
+// readEntry decodes and verifies the key pair of 'entry' without adding it to the manager. 'namesInFile' collects the names read so far.
+func (keyPairHandler *KeyPairManager) readEntry(entry KeyStoreEntry, namesInFile map[string]bool) (*ed25519.KeyPair, error) {
+	if entry.Name != "" {
+		_, isKeyPairName := keyPairHandler.names[entry.Name]
+		_, isAgentName := keyPairHandler.agents[entry.Name]
+		if isKeyPairName || isAgentName || namesInFile[entry.Name] {
+			return nil, fmt.Errorf("name %s is already in use", entry.Name)
+		}
+		namesInFile[entry.Name] = true
+	}
+
+	var keyPair *ed25519.KeyPair
+	switch {
+	case entry.Seed != "":
+		seedBytes, err := base58.Decode(entry.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed: %w", err)
+		}
+		if len(seedBytes) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid seed: expected %d bytes, got %d", ed25519.SeedSize, len(seedBytes))
+		}
+		keyPair, _ = keyPairHandler.env.NewKeyPair(ed25519.NewSeed(seedBytes))
+	case entry.PrivateKey != "":
+		privateKeyBytes, err := base58.Decode(entry.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		privateKey, err, _ := ed25519.PrivateKeyFromBytes(privateKeyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		keyPair = &ed25519.KeyPair{PrivateKey: privateKey, PublicKey: privateKey.Public()}
+	default:
+		return nil, fmt.Errorf("neither seed nor private key is defined")
+	}
+
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	if entry.Address != "" && entry.Address != address.Base58() {
+		return nil, fmt.Errorf("address %s does not match the key pair, whose address is %s", entry.Address, address.Base58())
+	}
+	agentID := keyPairHandler.MustGetAgentID(keyPair)
+	if entry.AgentID != "" && entry.AgentID != agentID.String() {
+		return nil, fmt.Errorf("AgentID %s does not match the key pair, whose AgentID is %s", entry.AgentID, agentID.String())
+	}
+	return keyPair, nil
+}
+
+func isYaml(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".yaml" || extension == ".yml"
+}
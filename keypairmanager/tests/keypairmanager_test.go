@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/brunoamancio/NotSolo/keypairmanager"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Named(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	aliceKeyPair := notSolo.KeyPair.Named("alice")
+
+	// Act
+	sameKeyPair := notSolo.KeyPair.Named("alice")
+	bobKeyPair := notSolo.KeyPair.Named("bob")
+
+	// Assert
+	require.Same(t, aliceKeyPair, sameKeyPair)
+	require.NotSame(t, aliceKeyPair, bobKeyPair)
+}
+
+func Test_GetByName(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	aliceKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	aliceAddress := notSolo.KeyPair.MustGetAddress(aliceKeyPair)
+	aliceAgentID := notSolo.KeyPair.MustGetAgentID(aliceKeyPair)
+
+	// Act
+	keyPairByName, okByName := notSolo.KeyPair.GetByName("alice")
+	keyPairByAddress, okByAddress := notSolo.KeyPair.GetByAddress(aliceAddress)
+	keyPairByAgentID, okByAgentID := notSolo.KeyPair.GetByAgentID(&aliceAgentID)
+	_, okUnknown := notSolo.KeyPair.GetByName("bob")
+
+	// Assert
+	require.True(t, okByName)
+	require.True(t, okByAddress)
+	require.True(t, okByAgentID)
+	require.False(t, okUnknown)
+	require.Same(t, aliceKeyPair, keyPairByName)
+	require.Same(t, aliceKeyPair, keyPairByAddress)
+	require.Same(t, aliceKeyPair, keyPairByAgentID)
+}
+
+func Test_NameOf(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	aliceKeyPair := notSolo.KeyPair.Named("alice")
+	anonymousKeyPair := notSolo.KeyPair.NewKeyPair()
+	anonymousAddress := notSolo.KeyPair.MustGetAddress(anonymousKeyPair)
+
+	// Act
+	aliceName := notSolo.KeyPair.NameOf(aliceKeyPair)
+	anonymousName := notSolo.KeyPair.NameOf(anonymousKeyPair)
+
+	// Assert
+	require.Equal(t, "alice", aliceName)
+	require.Equal(t, anonymousAddress.Base58(), anonymousName)
+}
+
+func Test_NewKeyPairWithBalance(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	const iotaAmount = uint64(1_000_000)
+	const mintAmount = uint64(5000)
+
+	// Act
+	keyPair, colors := notSolo.KeyPair.NewKeyPairWithBalance(iotaAmount, mintAmount)
+
+	// Assert
+	require.Len(t, colors, 1)
+	notSolo.KeyPair.RequireL1Balance(keyPair, colored.IOTA, iotaAmount)
+	notSolo.KeyPair.RequireL1Balance(keyPair, colors[0], mintAmount)
+
+	// The funds can be spent at once
+	receiverKeyPair := notSolo.KeyPair.NewKeyPair()
+	notSolo.L1.MustTransferL1(keyPair, notSolo.KeyPair.MustGetAddress(receiverKeyPair), colored.IOTA, iotaAmount)
+	notSolo.KeyPair.RequireL1Balance(receiverKeyPair, colored.IOTA, iotaAmount)
+}
+
+func Test_NewKeyPairWithBalance_SeveralFaucetCalls(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	const iotaAmount = 3*utxodb.RequestFundsAmount + 1
+
+	// Act
+	keyPair, colors := notSolo.KeyPair.NewKeyPairWithBalance(iotaAmount)
+
+	// Assert
+	require.Empty(t, colors)
+	notSolo.KeyPair.RequireL1Balance(keyPair, colored.IOTA, iotaAmount)
+	notSolo.L1.RequireOutputCount(notSolo.KeyPair.MustGetAddress(keyPair), 1)
+}
+
+func Test_ExportImport(t *testing.T) {
+	for _, fileName := range []string{"keystore.json", "keystore.yaml"} {
+		t.Run(fileName, func(t *testing.T) {
+			// Arrange
+			notSolo := notsolo.New(t)
+			aliceKeyPair := notSolo.KeyPair.Named("alice")
+			anonymousKeyPair := notSolo.KeyPair.NewKeyPair()
+			path := filepath.Join(t.TempDir(), fileName)
+			notSolo.KeyPair.MustExport(path)
+
+			// Act
+			otherNotSolo := notsolo.New(t)
+			importedKeyPairs := otherNotSolo.KeyPair.MustImport(path)
+
+			// Assert
+			require.Len(t, importedKeyPairs, 2)
+			require.Equal(t, *aliceKeyPair, *otherNotSolo.KeyPair.Named("alice"))
+			require.Equal(t, *anonymousKeyPair, *importedKeyPairs[1])
+		})
+	}
+}
+
+func Test_Import_InvalidKeys(t *testing.T) {
+	shortKey := base58.Encode([]byte("too short"))
+	for name, entry := range map[string]keypairmanager.KeyStoreEntry{
+		"seed":       {Seed: shortKey},
+		"privateKey": {PrivateKey: shortKey},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			notSolo := notsolo.New(t)
+			path := filepath.Join(t.TempDir(), "keystore.json")
+			data, err := json.Marshal([]keypairmanager.KeyStoreEntry{entry})
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, data, 0600))
+
+			// Act
+			keyPairs, err := notSolo.KeyPair.Import(path)
+
+			// Assert
+			require.Error(t, err)
+			require.Nil(t, keyPairs)
+			require.Empty(t, notSolo.KeyPair.GetKeyPairs())
+		})
+	}
+}
+
+func Test_Import_IsAtomic(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	otherNotSolo := notsolo.New(t)
+	carolKeyPair := otherNotSolo.KeyPair.NewKeyPair()
+	validEntry := keypairmanager.KeyStoreEntry{Name: "carol", PrivateKey: base58.Encode(carolKeyPair.PrivateKey.Bytes())}
+	invalidEntry := keypairmanager.KeyStoreEntry{Name: "dave", Seed: base58.Encode([]byte("too short"))}
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	data, err := json.Marshal([]keypairmanager.KeyStoreEntry{validEntry, invalidEntry})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	// Act
+	keyPairs, err := notSolo.KeyPair.Import(path)
+
+	// Assert - the valid entry before the invalid one is not added either
+	require.Error(t, err)
+	require.Nil(t, keyPairs)
+	require.Empty(t, notSolo.KeyPair.GetKeyPairs())
+	_, ok := notSolo.KeyPair.GetByName("carol")
+	require.False(t, ok)
+}
+
+func Test_Import_NameOfAgent(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+	notSolo.KeyPair.NamedAlias("carol")
+	otherNotSolo := notsolo.New(t)
+	otherNotSolo.KeyPair.Named("carol")
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	otherNotSolo.KeyPair.MustExport(path)
+
+	// Act
+	keyPairs, err := notSolo.KeyPair.Import(path)
+
+	// Assert
+	require.Error(t, err)
+	require.Nil(t, keyPairs)
+	require.Empty(t, notSolo.KeyPair.GetKeyPairs())
+}
@@ -0,0 +1,218 @@
+package l1ledger
+
+import (
+	"errors"
+
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/hive.go/identity"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/solo"
+)
+
+// ErrInsufficientFunds is returned when an address does not hold the balances to be transfered
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrZeroAmount is returned when the balances to be transfered are empty or zero
+var ErrZeroAmount = errors.New("nothing to transfer")
+
+// ErrSelfTransfer is returned when the sender and the receiver of a transfer are the same address
+var ErrSelfTransfer = errors.New("sender and receiver are the same address")
+
+// ErrTooManyInputs is returned when the balances to be transfered are spread over more outputs than one transaction can spend.
+// See Consolidate.
+var ErrTooManyInputs = errors.New("balances are spread over too many outputs, consolidate the address first")
+
+// GetOutputs returns the unspent outputs of 'address' in the L1 ledger of solo
+func GetOutputs(env *solo.Solo, address ledgerstate.Address) []ledgerstate.Output {
+	return env.GetAddressOutputs(address)
+}
+
+// GetBalances sums the balances of 'outputs' by color
+func GetBalances(outputs []ledgerstate.Output) colored.Balances {
+	balances := colored.Balances{}
+	for _, output := range outputs {
+		output.Balances().ForEach(func(color ledgerstate.Color, balance uint64) bool {
+			balances[colored.Color(color)] += balance
+			return true
+		})
+	}
+	return balances
+}
+
+// GetTotalBalances sums the balances of 'addresses' by color. Addresses which appear more than once are counted once.
+func GetTotalBalances(env *solo.Solo, addresses []ledgerstate.Address) colored.Balances {
+	totalBalances := colored.Balances{}
+	isCounted := make(map[string]bool)
+	for _, address := range addresses {
+		if isCounted[address.Base58()] {
+			continue
+		}
+		isCounted[address.Base58()] = true
+
+		for color, balance := range env.GetAddressBalances(address) {
+			totalBalances[color] += balance
+		}
+	}
+	return totalBalances
+}
+
+// Transfer moves 'balances' from the address of 'senderKeyPair' to 'receiverAddress' in the L1 ledger of solo. The remainder stays
+// with the sender. Returns ErrZeroAmount if 'balances' are empty, ErrSelfTransfer if 'receiverAddress' is the address of the sender,
+// ErrInsufficientFunds if the sender does not hold 'balances' and ErrTooManyInputs if they are spread over more than
+// ledgerstate.MaxInputCount outputs.
+func Transfer(env *solo.Solo, senderKeyPair *ed25519.KeyPair, receiverAddress ledgerstate.Address, balances colored.Balances) error {
+	senderAddress := ledgerstate.NewED25519Address(senderKeyPair.PublicKey)
+	if isEmpty(balances) {
+		return ErrZeroAmount
+	}
+	if receiverAddress.Equals(senderAddress) {
+		return ErrSelfTransfer
+	}
+
+	inputs, err := selectInputs(GetOutputs(env, senderAddress), balances)
+	if err != nil {
+		return err
+	}
+
+	remainder := GetBalances(inputs)
+	for color, balance := range balances {
+		remainder[color] -= balance
+	}
+
+	outputs := []ledgerstate.Output{newOutput(receiverAddress, balances)}
+	if !isEmpty(remainder) {
+		outputs = append(outputs, newOutput(senderAddress, remainder))
+	}
+
+	return addTransaction(env, senderKeyPair, inputs, outputs)
+}
+
+// Uncolor converts 'amount' of 'color' of the address of 'keyPair' back into IOTA in the L1 ledger of solo.
+// Returns ErrZeroAmount if 'amount' is 0, ErrInsufficientFunds if the address does not hold 'amount' of 'color' and ErrTooManyInputs
+// if it is spread over more than ledgerstate.MaxInputCount outputs.
+func Uncolor(env *solo.Solo, keyPair *ed25519.KeyPair, color colored.Color, amount uint64) error {
+	if amount == 0 {
+		return ErrZeroAmount
+	}
+
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	inputs, err := selectInputs(GetOutputs(env, address), colored.Balances{color: amount})
+	if err != nil {
+		return err
+	}
+
+	balances := GetBalances(inputs)
+	balances[color] -= amount
+	balances[colored.IOTA] += amount
+
+	outputs := []ledgerstate.Output{newOutput(address, balances)}
+	return addTransaction(env, keyPair, inputs, outputs)
+}
+
+// Consolidate merges the outputs of the address of 'keyPair' into a single output in the L1 ledger of solo, spending at most
+// ledgerstate.MaxInputCount outputs per transaction
+func Consolidate(env *solo.Solo, keyPair *ed25519.KeyPair) error {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	for outputs := GetOutputs(env, address); len(outputs) > 1; outputs = GetOutputs(env, address) {
+		if len(outputs) > ledgerstate.MaxInputCount {
+			outputs = outputs[:ledgerstate.MaxInputCount]
+		}
+		err := addTransaction(env, keyPair, outputs, []ledgerstate.Output{newOutput(address, GetBalances(outputs))})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectInputs picks from 'outputs' the ones needed to cover 'balances'. Returns ErrInsufficientFunds if 'outputs' do not hold
+// 'balances' and ErrTooManyInputs if more than ledgerstate.MaxInputCount outputs would be needed.
+func selectInputs(outputs []ledgerstate.Output, balances colored.Balances) ([]ledgerstate.Output, error) {
+	missing := colored.Balances{}
+	for color, balance := range balances {
+		if balance > 0 {
+			missing[color] = balance
+		}
+	}
+
+	var inputs []ledgerstate.Output
+	for _, output := range outputs {
+		if len(missing) == 0 {
+			break
+		}
+
+		isNeeded := false
+		output.Balances().ForEach(func(color ledgerstate.Color, balance uint64) bool {
+			if _, ok := missing[colored.Color(color)]; ok {
+				isNeeded = true
+			}
+			return true
+		})
+		if !isNeeded {
+			continue
+		}
+
+		inputs = append(inputs, output)
+		output.Balances().ForEach(func(color ledgerstate.Color, balance uint64) bool {
+			if missingBalance, ok := missing[colored.Color(color)]; ok {
+				if balance >= missingBalance {
+					delete(missing, colored.Color(color))
+				} else {
+					missing[colored.Color(color)] = missingBalance - balance
+				}
+			}
+			return true
+		})
+	}
+
+	if len(missing) > 0 || len(inputs) == 0 {
+		return nil, ErrInsufficientFunds
+	}
+	if len(inputs) > ledgerstate.MaxInputCount {
+		return nil, ErrTooManyInputs
+	}
+	return inputs, nil
+}
+
+// newOutput creates an output which holds the non-zero 'balances' of 'address'
+func newOutput(address ledgerstate.Address, balances colored.Balances) ledgerstate.Output {
+	l1Balances := make(map[ledgerstate.Color]uint64, len(balances))
+	for color, balance := range balances {
+		if balance > 0 {
+			l1Balances[ledgerstate.Color(color)] = balance
+		}
+	}
+	return ledgerstate.NewSigLockedColoredOutput(ledgerstate.NewColoredBalances(l1Balances), address)
+}
+
+// addTransaction spends all 'inputs', which must belong to 'signerKeyPair', into 'outputs' and adds the transaction to the L1 ledger of solo
+func addTransaction(env *solo.Solo, signerKeyPair *ed25519.KeyPair, inputs []ledgerstate.Output, outputs []ledgerstate.Output) error {
+	utxoInputs := make([]ledgerstate.Input, len(inputs))
+	for i, input := range inputs {
+		utxoInputs[i] = ledgerstate.NewUTXOInput(input.ID())
+	}
+
+	essence := ledgerstate.NewTransactionEssence(0, env.LogicalTime(), identity.ID{}, identity.ID{},
+		ledgerstate.NewInputs(utxoInputs...), ledgerstate.NewOutputs(outputs...))
+
+	// All inputs belong to the same address: the first one is signed and the others refer to it
+	signature := ledgerstate.NewED25519Signature(signerKeyPair.PublicKey, signerKeyPair.PrivateKey.Sign(essence.Bytes()))
+	unlockBlocks := make(ledgerstate.UnlockBlocks, len(utxoInputs))
+	unlockBlocks[0] = ledgerstate.NewSignatureUnlockBlock(signature)
+	for i := 1; i < len(unlockBlocks); i++ {
+		unlockBlocks[i] = ledgerstate.NewReferenceUnlockBlock(0)
+	}
+
+	transaction := ledgerstate.NewTransaction(essence, unlockBlocks)
+	return env.AddToLedger(transaction)
+}
+
+func isEmpty(balances colored.Balances) bool {
+	for _, balance := range balances {
+		if balance > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,9 @@
 package l1manager
 
 import (
+	"github.com/brunoamancio/NotSolo/keypairmanager"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/brunoamancio/NotSolo/requestobserver"
 	"github.com/iotaledger/goshimmer/packages/ledgerstate"
 	"github.com/iotaledger/hive.go/crypto/ed25519"
 
@@ -12,23 +15,38 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// L1Manager manipulates chains.
+// Output is an unspent output of an address in L1
+type Output struct {
+	ID       ledgerstate.OutputID
+	Type     ledgerstate.OutputType
+	Balances colored.Balances
+}
+
+// L1Manager manipulates balances in L1
 type L1Manager struct {
-	env *solo.Solo
+	env            *solo.Solo
+	keyPairManager *keypairmanager.KeyPairManager
+	observers      requestobserver.Observers
 }
 
-// New instantiates a chain manager.
-func New(env *solo.Solo) *L1Manager {
-	l1Manager := &L1Manager{env: env}
+// New instantiates an L1 manager
+func New(env *solo.Solo, keyPairManager *keypairmanager.KeyPairManager) *L1Manager {
+	l1Manager := &L1Manager{env: env, keyPairManager: keyPairManager}
 	return l1Manager
 }
 
+// AddRequestObserver makes 'observer' be notified of every request posted by the manager
+func (l1Manager *L1Manager) AddRequestObserver(observer requestobserver.RequestObserver) {
+	l1Manager.observers.Add(observer)
+}
+
 // MustTransferToChain makes transfer of 'amount' of 'color' from the depositors account in L1 to the receivers account in 'chain'.
 // Transfers to 'depositor' if no receiver is defined.
 // Fails test on error.
 func (l1Manager *L1Manager) MustTransferToChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
 	receiverKeyPair *ed25519.KeyPair) {
-	l1Manager.TransferToChain(depositorKeyPair, chain, color, transferAmount, receiverKeyPair)
+	err := l1Manager.TransferToChain(depositorKeyPair, chain, color, transferAmount, receiverKeyPair)
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to chain", l1Manager.keyPairManager.NameOf(depositorKeyPair))
 }
 
 // TransferToChain makes transfer of 'amount' of 'color' from the depositors account in L1 to the receivers account in 'chain'.
@@ -45,14 +63,40 @@ func (l1Manager *L1Manager) TransferToChain(depositorKeyPair *ed25519.KeyPair, c
 	receiverAgentID := iscp.NewAgentID(receiverAddress, 0)
 
 	// Transfer
-	err := transferToAgent(depositorKeyPair, chain, color, transferAmount, receiverAgentID)
+	err := l1Manager.transferToAgent(depositorKeyPair, chain, colored.Balances{color: transferAmount}, receiverAgentID)
+	return err
+}
+
+// MustTransferBalancesToChain makes transfer of 'balances' (any number of colors) from the depositors account in L1 to the receivers account
+// in 'chain' with a single request. Transfers to 'depositor' if no receiver is defined. Fails test on error.
+func (l1Manager *L1Manager) MustTransferBalancesToChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, balances colored.Balances,
+	receiverKeyPair *ed25519.KeyPair) {
+	err := l1Manager.TransferBalancesToChain(depositorKeyPair, chain, balances, receiverKeyPair)
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to chain", l1Manager.keyPairManager.NameOf(depositorKeyPair))
+}
+
+// TransferBalancesToChain makes transfer of 'balances' (any number of colors) from the depositors account in L1 to the receivers account
+// in 'chain' with a single request. Transfers to 'depositor' if no receiver is defined.
+func (l1Manager *L1Manager) TransferBalancesToChain(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, balances colored.Balances,
+	receiverKeyPair *ed25519.KeyPair) error {
+
+	isReceiverDefined := receiverKeyPair != nil
+
+	if !isReceiverDefined {
+		receiverKeyPair = depositorKeyPair
+	}
+	receiverAddress := ledgerstate.NewED25519Address(receiverKeyPair.PublicKey)
+	receiverAgentID := iscp.NewAgentID(receiverAddress, 0)
+
+	// Transfer
+	err := l1Manager.transferToAgent(depositorKeyPair, chain, balances, receiverAgentID)
 	return err
 }
 
 // MustTransferToChainToSelf makes transfer of 'amount' of 'color' from the depositors account in L1 to the depositors account in 'chain'. Fails test on error.
 func (l1Manager *L1Manager) MustTransferToChainToSelf(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, amount uint64) {
 	err := l1Manager.TransferToChainToSelf(depositorKeyPair, chain, color, amount)
-	require.NoError(l1Manager.env.T, err, "Could not complete transfer to self")
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to self", l1Manager.keyPairManager.NameOf(depositorKeyPair))
 }
 
 // TransferToChainToSelf makes transfer of 'amount' of 'color' from the depositors account in L1 to the depositors account in 'chain'.
@@ -67,7 +111,7 @@ func (l1Manager *L1Manager) MustTransferToContract(depositorKeyPair *ed25519.Key
 	contractName string) {
 
 	err := l1Manager.TransferToContract(depositorKeyPair, chain, color, transferAmount, contractName)
-	require.NoError(l1Manager.env.T, err)
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to contract %s", l1Manager.keyPairManager.NameOf(depositorKeyPair), contractName)
 }
 
 // TransferToContract makes transfer of 'amount' of 'color' from the depositors account in L1 to the contract's account in 'chain'.
@@ -92,19 +136,67 @@ func (l1Manager *L1Manager) TransferToContract(depositorKeyPair *ed25519.KeyPair
 	contractAgentID := iscp.NewAgentID(chain.ChainID.AsAddress(), contractRecord.Hname())
 
 	// Transfer
-	err = transferToAgent(depositorKeyPair, chain, color, transferAmount, contractAgentID)
+	err = l1Manager.transferToAgent(depositorKeyPair, chain, colored.Balances{color: transferAmount}, contractAgentID)
 	return err
 }
 
-func transferToAgent(depositorKeypair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+// MustTransferToAgent makes transfer of 'amount' of 'color' from the depositors account in L1 to the account of 'agentID' in 'chain'.
+// Fails test on error.
+func (l1Manager *L1Manager) MustTransferToAgent(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+	agentID *iscp.AgentID) {
+	err := l1Manager.TransferToAgent(depositorKeyPair, chain, color, transferAmount, agentID)
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to %s", l1Manager.keyPairManager.NameOf(depositorKeyPair),
+		l1Manager.keyPairManager.NameOfAgentID(agentID))
+}
+
+// TransferToAgent makes transfer of 'amount' of 'color' from the depositors account in L1 to the account of 'agentID' in 'chain'.
+func (l1Manager *L1Manager) TransferToAgent(depositorKeyPair *ed25519.KeyPair, chain *solo.Chain, color colored.Color, transferAmount uint64,
+	agentID *iscp.AgentID) error {
+	return l1Manager.transferToAgent(depositorKeyPair, chain, colored.Balances{color: transferAmount}, agentID)
+}
+
+func (l1Manager *L1Manager) transferToAgent(depositorKeypair *ed25519.KeyPair, chain *solo.Chain, balances colored.Balances,
 	agentID *iscp.AgentID) error {
 
 	params := solo.NewCallParams(accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, codec.EncodeAgentID(agentID))
-	depositRequest := params.WithTransfer(color, transferAmount)
-	_, err := chain.PostRequestSync(depositRequest, depositorKeypair)
+	depositRequest := params.WithTransfers(balances)
+	_, err := l1Manager.observers.PostRequestSync(chain, depositRequest, depositorKeypair)
 	return err
 }
 
+// MustTransferL1 makes transfer of 'amount' of 'color' from the senders address in L1 to 'receiverAddress' in L1, with a single transaction
+// in the UTXO ledger of solo. Fails test on error.
+func (l1Manager *L1Manager) MustTransferL1(senderKeyPair *ed25519.KeyPair, receiverAddress ledgerstate.Address, color colored.Color, amount uint64) {
+	err := l1Manager.TransferL1(senderKeyPair, receiverAddress, color, amount)
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to %s in L1", l1Manager.keyPairManager.NameOf(senderKeyPair),
+		l1Manager.keyPairManager.NameOfAddress(receiverAddress))
+}
+
+// TransferL1 makes transfer of 'amount' of 'color' from the senders address in L1 to 'receiverAddress' in L1, with a single transaction
+// in the UTXO ledger of solo. Returns l1ledger.ErrZeroAmount if 'amount' is 0, l1ledger.ErrSelfTransfer if 'receiverAddress' is the address of the sender
+// and l1ledger.ErrInsufficientFunds if the sender does not hold 'amount' of 'color'.
+func (l1Manager *L1Manager) TransferL1(senderKeyPair *ed25519.KeyPair, receiverAddress ledgerstate.Address, color colored.Color, amount uint64) error {
+	return l1Manager.TransferBalancesL1(senderKeyPair, receiverAddress, colored.Balances{color: amount})
+}
+
+// MustTransferBalancesL1 makes transfer of 'balances' (any number of colors) from the senders address in L1 to 'receiverAddress' in L1,
+// with a single transaction in the UTXO ledger of solo. Fails test on error.
+func (l1Manager *L1Manager) MustTransferBalancesL1(senderKeyPair *ed25519.KeyPair, receiverAddress ledgerstate.Address, balances colored.Balances) {
+	err := l1Manager.TransferBalancesL1(senderKeyPair, receiverAddress, balances)
+	require.NoError(l1Manager.env.T, err, "Could not complete transfer of %s to %s in L1", l1Manager.keyPairManager.NameOf(senderKeyPair),
+		l1Manager.keyPairManager.NameOfAddress(receiverAddress))
+}
+
+// TransferBalancesL1 makes transfer of 'balances' (any number of colors) from the senders address in L1 to 'receiverAddress' in L1,
+// with a single transaction in the UTXO ledger of solo. Returns l1ledger.ErrZeroAmount if 'balances' are empty, l1ledger.ErrSelfTransfer if
+// 'receiverAddress' is the address of the sender and l1ledger.ErrInsufficientFunds if the sender does not hold 'balances'.
+func (l1Manager *L1Manager) TransferBalancesL1(senderKeyPair *ed25519.KeyPair, receiverAddress ledgerstate.Address, balances colored.Balances) error {
+	senderAddress := ledgerstate.NewED25519Address(senderKeyPair.PublicKey)
+	return l1Manager.observers.Notify(nil, []ledgerstate.Address{senderAddress, receiverAddress}, func() error {
+		return l1ledger.Transfer(l1Manager.env, senderKeyPair, receiverAddress, balances)
+	})
+}
+
 // RequireBalance verifies if the key pair has the expected balance of 'color' in L1.
 // Fails test if balance is not equal to expectedBalance.
 func (l1Manager *L1Manager) RequireBalance(keyPair *ed25519.KeyPair, color colored.Color, expectedBalance uint64) {
@@ -115,5 +207,58 @@ func (l1Manager *L1Manager) RequireBalance(keyPair *ed25519.KeyPair, color color
 // RequireAddressBalance verifies if the address has the expected balance of 'color' in L1.
 // Fails test if balance is not equal to expectedBalance.
 func (l1Manager *L1Manager) RequireAddressBalance(address ledgerstate.Address, color colored.Color, expectedBalance uint64) {
-	l1Manager.env.AssertAddressBalance(address, color, expectedBalance)
+	balance := l1Manager.GetAddressBalance(address, color)
+	require.EqualValues(l1Manager.env.T, expectedBalance, balance, "Unexpected balance of %s in L1 for color %s", l1Manager.keyPairManager.NameOfAddress(address),
+		l1Manager.keyPairManager.NameOfColor(color))
+}
+
+// GetL1Balance returns the balance of 'color' of the key pair in L1
+func (l1Manager *L1Manager) GetL1Balance(keyPair *ed25519.KeyPair, color colored.Color) uint64 {
+	return l1Manager.GetAddressBalance(ledgerstate.NewED25519Address(keyPair.PublicKey), color)
+}
+
+// GetL1Balances returns the balances of all colors of the key pair in L1
+func (l1Manager *L1Manager) GetL1Balances(keyPair *ed25519.KeyPair) colored.Balances {
+	return l1Manager.GetAddressBalances(ledgerstate.NewED25519Address(keyPair.PublicKey))
+}
+
+// GetAddressBalance returns the balance of 'color' of the address in L1
+func (l1Manager *L1Manager) GetAddressBalance(address ledgerstate.Address, color colored.Color) uint64 {
+	return l1Manager.env.GetAddressBalance(address, color)
+}
+
+// GetAddressBalances returns the balances of all colors of the address in L1
+func (l1Manager *L1Manager) GetAddressBalances(address ledgerstate.Address) colored.Balances {
+	return l1Manager.env.GetAddressBalances(address)
+}
+
+// GetOutputs returns the unspent outputs of the address in L1, with their IDs, types and balances
+func (l1Manager *L1Manager) GetOutputs(address ledgerstate.Address) []Output {
+	l1Outputs := l1ledger.GetOutputs(l1Manager.env, address)
+	outputs := make([]Output, 0, len(l1Outputs))
+	for _, l1Output := range l1Outputs {
+		output := Output{ID: l1Output.ID(), Type: l1Output.Type(), Balances: l1ledger.GetBalances([]ledgerstate.Output{l1Output})}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+// RequireOutputCount verifies if the address has the expected number of unspent outputs in L1.
+// Fails test if the number of outputs is not equal to expectedCount.
+func (l1Manager *L1Manager) RequireOutputCount(address ledgerstate.Address, expectedCount int) {
+	outputs := l1Manager.GetOutputs(address)
+	require.Len(l1Manager.env.T, outputs, expectedCount, "Unexpected number of outputs of %s in L1", l1Manager.keyPairManager.NameOfAddress(address))
+}
+
+// RequireOutputCountOfType verifies if the address has the expected number of unspent outputs of 'outputType' in L1.
+// Fails test if the number of outputs of 'outputType' is not equal to expectedCount.
+func (l1Manager *L1Manager) RequireOutputCountOfType(address ledgerstate.Address, outputType ledgerstate.OutputType, expectedCount int) {
+	count := 0
+	for _, output := range l1Manager.GetOutputs(address) {
+		if output.Type == outputType {
+			count++
+		}
+	}
+	require.Equal(l1Manager.env.T, expectedCount, count, "Unexpected number of outputs of type %s of %s in L1", outputType,
+		l1Manager.keyPairManager.NameOfAddress(address))
 }
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"testing"
+
+	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TransferToAgent(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and the identities of another chain and of an alias address
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	otherChainAgentID := notSolo.KeyPair.NamedChain("otherChain", notSolo.Chain.NewChain(nil, "myOtherChain"))
+	aliasAgentID := notSolo.KeyPair.NamedAlias("alias")
+
+	// Send funds to both identities in chain
+	senderKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	transferAmount := uint64(100)
+	notSolo.L1.MustTransferToAgent(senderKeyPair, chain, colored.IOTA, transferAmount, otherChainAgentID)
+	notSolo.L1.MustTransferToAgent(senderKeyPair, chain, colored.IOTA, transferAmount, aliasAgentID)
+	notSolo.Chain.RequireAgentBalance(otherChainAgentID, chain, colored.IOTA, transferAmount)
+	notSolo.Chain.RequireAgentBalance(aliasAgentID, chain, colored.IOTA, transferAmount)
+}
+
+func Test_TransferL1(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a sender with dummy funds (amount is defined in utxodb.RequestFundsAmount) and a receiver without funds
+	senderKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	receiverKeyPair := notSolo.KeyPair.Named("bob")
+	receiverAddress := notSolo.KeyPair.MustGetAddress(receiverKeyPair)
+	transferAmount := uint64(100)
+
+	// Send funds in L1
+	notSolo.L1.MustTransferL1(senderKeyPair, receiverAddress, colored.IOTA, transferAmount)
+	notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, utxodb.RequestFundsAmount-transferAmount)
+	notSolo.L1.RequireBalance(receiverKeyPair, colored.IOTA, transferAmount)
+
+	// Sending more than the balance fails
+	err := notSolo.L1.TransferL1(receiverKeyPair, notSolo.KeyPair.MustGetAddress(senderKeyPair), colored.IOTA, transferAmount+1)
+	require.ErrorIs(t, err, l1ledger.ErrInsufficientFunds)
+	notSolo.L1.RequireBalance(receiverKeyPair, colored.IOTA, transferAmount)
+
+	// Sending nothing or sending to oneself fails
+	err = notSolo.L1.TransferL1(senderKeyPair, receiverAddress, colored.IOTA, 0)
+	require.ErrorIs(t, err, l1ledger.ErrZeroAmount)
+	err = notSolo.L1.TransferBalancesL1(senderKeyPair, receiverAddress, colored.Balances{})
+	require.ErrorIs(t, err, l1ledger.ErrZeroAmount)
+	err = notSolo.L1.TransferL1(senderKeyPair, notSolo.KeyPair.MustGetAddress(senderKeyPair), colored.IOTA, transferAmount)
+	require.ErrorIs(t, err, l1ledger.ErrSelfTransfer)
+	notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, utxodb.RequestFundsAmount-transferAmount)
+}
+
+func Test_GetOutputs(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a sender with dummy funds (amount is defined in utxodb.RequestFundsAmount) and a receiver without funds
+	senderKeyPair := notSolo.KeyPair.NamedWithFunds("alice")
+	receiverAddress := notSolo.KeyPair.MustGetAddress(notSolo.KeyPair.Named("bob"))
+	transferAmount := uint64(100)
+
+	// Send funds twice in L1. The receiver gets one output per transfer, the sender keeps a single remainder output.
+	notSolo.L1.MustTransferL1(senderKeyPair, receiverAddress, colored.IOTA, transferAmount)
+	notSolo.L1.MustTransferL1(senderKeyPair, receiverAddress, colored.IOTA, transferAmount)
+	notSolo.L1.RequireOutputCount(notSolo.KeyPair.MustGetAddress(senderKeyPair), 1)
+	notSolo.L1.RequireOutputCount(receiverAddress, 2)
+	notSolo.L1.RequireOutputCountOfType(receiverAddress, ledgerstate.SigLockedColoredOutputType, 2)
+
+	for _, output := range notSolo.L1.GetOutputs(receiverAddress) {
+		require.Equal(t, colored.Balances{colored.IOTA: transferAmount}, output.Balances)
+	}
+}
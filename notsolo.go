@@ -3,31 +3,37 @@ package notsolo
 import (
 	"testing"
 
+	"github.com/brunoamancio/NotSolo/balancetracker"
 	"github.com/brunoamancio/NotSolo/chainmanager"
 	"github.com/brunoamancio/NotSolo/coloredtokenmanager"
+	"github.com/brunoamancio/NotSolo/conservationchecker"
 	"github.com/brunoamancio/NotSolo/datamanager"
 	"github.com/brunoamancio/NotSolo/keypairmanager"
 	"github.com/brunoamancio/NotSolo/l1manager"
+	"github.com/brunoamancio/NotSolo/recipienttracker"
 	"github.com/brunoamancio/NotSolo/requestmanager"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
 	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
 )
 
 // NotSolo is a wrapper around solo to simplify unit testing
 type NotSolo struct {
-	t               *testing.T
-	debug           bool
-	printStackTrace bool
-	env             *solo.Solo
-	KeyPair         *keypairmanager.KeyPairManager
-	ColoredToken    *coloredtokenmanager.ColoredTokenManager
-	L1              *l1manager.L1Manager
-	Chain           *chainmanager.ChainManager
-	Request         *requestmanager.RequestManager
-	Data            *datamanager.DataManager
+	t            *testing.T
+	settings     settings
+	isPooled     bool
+	seed         *ed25519.Seed
+	env          *solo.Solo
+	KeyPair      *keypairmanager.KeyPairManager
+	ColoredToken *coloredtokenmanager.ColoredTokenManager
+	L1           *l1manager.L1Manager
+	Chain        *chainmanager.ChainManager
+	Request      *requestmanager.RequestManager
+	Data         *datamanager.DataManager
+	recipients   *recipienttracker.RecipientTracker
 }
 
-var notSolo *NotSolo = &NotSolo{}
-
 // Initializable defines a contract to verify whether a structure is initialized
 type Initializable interface {
 	IsInitialized() bool
@@ -43,31 +49,131 @@ func (notSolo *NotSolo) IsInitialized() bool {
 	return notSolo.env != nil
 }
 
-// Dispose implements Disposable for NotSolo
+// Dispose implements Disposable for NotSolo. Disposes every manager which implements Disposable.
+// Managers only forget their state: solo releases none of its resources before the test binary exits (see ChainManager.Dispose).
 func (notSolo *NotSolo) Dispose() {
-	notSolo.Chain.Dispose()
+	managers := []interface{}{notSolo.KeyPair, notSolo.ColoredToken, notSolo.L1, notSolo.Chain, notSolo.Request, notSolo.Data, notSolo.recipients}
+	for _, manager := range managers {
+		if disposable, ok := manager.(Disposable); ok {
+			disposable.Dispose()
+		}
+	}
 }
 
-// New instantiates NotSolo with default settings
-func New(t *testing.T) *NotSolo {
-	if notSolo.IsInitialized() {
-		notSolo.Dispose()
-		notSolo.env.T = t
+// New instantiates NotSolo configured by 'options'. Environment variables override options (see EnvDebug, EnvStackTrace and EnvSeed).
+// Every call returns an independent instance (own solo environment, key pairs and chains), so tests may call t.Parallel().
+// The instance is disposed when the test finishes.
+func New(t *testing.T, options ...Option) *NotSolo {
+	settings, err := newSettings(options...)
+	require.NoError(t, err, "Could not read NotSolo settings")
+
+	notSolo := newNotSolo(t, settings)
+	t.Cleanup(notSolo.cleanUp)
+	return notSolo
+}
+
+// NewPooled instantiates NotSolo configured by 'options', reusing an idle instance with the same settings from a previous test when available.
+// The instance is disposed and returned to the pool when the test finishes. It is never shared by two running tests,
+// so tests may call t.Parallel(). L1 balances and chains created by previous tests remain in the reused solo environment.
+func NewPooled(t *testing.T, options ...Option) *NotSolo {
+	settings, err := newSettings(options...)
+	require.NoError(t, err, "Could not read NotSolo settings")
+
+	notSolo := pool.get(settings)
+	if notSolo == nil {
+		notSolo = newNotSolo(t, settings)
+		notSolo.isPooled = true
 	} else {
-		loadManagers(t)
+		notSolo.t = t
+		notSolo.env.T = t
 	}
 
-	notSolo.t = t
+	t.Cleanup(func() {
+		notSolo.cleanUp()
+		pool.put(notSolo)
+	})
 	return notSolo
 }
 
-func loadManagers(t *testing.T) {
-	notSolo.env = solo.New(t, notSolo.debug, notSolo.printStackTrace)
+// cleanUp disposes the instance.
+// Prints the seed if the test failed, so that its key pairs can be reproduced. Pooled instances do not print it: their key pairs
+// are derived from an index shared with the previous tests, so the seed alone does not reproduce them.
+func (notSolo *NotSolo) cleanUp() {
+	if notSolo.t.Failed() && !notSolo.isPooled {
+		notSolo.t.Logf("NotSolo seed: %s. Set %s=%s to reproduce its key pairs and addresses.", notSolo.seed, EnvSeed, notSolo.seed)
+	}
+
+	notSolo.Dispose()
+}
+
+func newNotSolo(t *testing.T, settings settings) *NotSolo {
+	seed := settings.getSeed()
+	if seed == nil {
+		seed = ed25519.NewSeed()
+	}
+
+	notSolo := &NotSolo{t: t, settings: settings, seed: seed}
+	notSolo.loadManagers()
+	return notSolo
+}
 
-	notSolo.KeyPair = keypairmanager.New(notSolo.env)
-	notSolo.ColoredToken = coloredtokenmanager.New(notSolo.env)
-	notSolo.Chain = chainmanager.New(notSolo.env)
-	notSolo.L1 = l1manager.New(notSolo.env)
-	notSolo.Request = requestmanager.New(notSolo.env)
+func (notSolo *NotSolo) loadManagers() {
+	notSolo.env = notSolo.newEnv()
+
+	notSolo.KeyPair = keypairmanager.New(notSolo.env, notSolo.seed)
+	notSolo.ColoredToken = coloredtokenmanager.New(notSolo.env, notSolo.KeyPair, notSolo.getKnownAddresses)
+	notSolo.KeyPair.SetMinter(notSolo.ColoredToken.MintColoredTokens)
+	notSolo.Chain = chainmanager.New(notSolo.env, notSolo.KeyPair)
+	notSolo.L1 = l1manager.New(notSolo.env, notSolo.KeyPair)
+	notSolo.Request = requestmanager.New(notSolo.env, notSolo.KeyPair)
 	notSolo.Data = datamanager.New(notSolo.env)
+
+	// Requesters and receivers are remembered before the other observers read the known addresses
+	notSolo.recipients = recipienttracker.New()
+	notSolo.L1.AddRequestObserver(notSolo.recipients)
+	notSolo.Chain.AddRequestObserver(notSolo.recipients)
+	notSolo.Request.AddRequestObserver(notSolo.recipients)
+
+	if notSolo.settings.conservationCheck {
+		conservationChecker := conservationchecker.New(notSolo.env, notSolo.getKnownAddresses)
+		notSolo.L1.AddRequestObserver(conservationChecker)
+		notSolo.Chain.AddRequestObserver(conservationChecker)
+		notSolo.Request.AddRequestObserver(conservationChecker)
+	}
+}
+
+// getKnownAddresses returns the L1 addresses of all key pairs and chains known to the managers and of the requesters and receivers of the
+// requests posted through them. Balances of chain accounts are included, since they are held by the address of the chain in L1.
+func (notSolo *NotSolo) getKnownAddresses() []ledgerstate.Address {
+	addresses := notSolo.recipients.GetRecipients()
+	for _, keyPair := range notSolo.KeyPair.GetKeyPairs() {
+		addresses = append(addresses, ledgerstate.NewED25519Address(keyPair.PublicKey))
+	}
+	for _, chain := range notSolo.Chain.GetChains() {
+		addresses = append(addresses, chain.ChainID.AsAddress())
+	}
+	return addresses
+}
+
+// newEnv instantiates solo with the seed of the instance
+func (notSolo *NotSolo) newEnv() *solo.Solo {
+	var env *solo.Solo
+	if notSolo.settings.logger != nil {
+		env = solo.NewWithLogger(notSolo.t, notSolo.settings.logger, notSolo.seed)
+	} else {
+		env = solo.New(notSolo.t, notSolo.settings.debug, notSolo.settings.printStackTrace, notSolo.seed)
+	}
+	return env
+}
+
+// GetSeed returns the seed of the L1 ledger and of the key pairs. Set EnvSeed to its base58 encoding to reproduce the key pairs and
+// addresses of a test run. Chain IDs and colors are not reproduced, since they depend on the wall-clock time at which solo creates its ledger.
+func (notSolo *NotSolo) GetSeed() *ed25519.Seed {
+	return notSolo.seed
+}
+
+// TrackBalances records the current balances of 'targets', so that their changes can be verified later. See balancetracker.New for the
+// supported targets. Fails test on error.
+func (notSolo *NotSolo) TrackBalances(targets ...interface{}) *balancetracker.BalanceTracker {
+	return balancetracker.New(notSolo.env, notSolo.KeyPair, targets...)
 }
@@ -0,0 +1,135 @@
+package notsolo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/hive.go/logger"
+	"github.com/mr-tron/base58"
+)
+
+const (
+	// EnvDebug enables debug logs of the VM when set to a true value (see strconv.ParseBool). Overrides WithDebug.
+	EnvDebug = "NOTSOLO_DEBUG"
+	// EnvStackTrace enables stack traces in the logs when set to a true value (see strconv.ParseBool). Overrides WithStackTrace.
+	EnvStackTrace = "NOTSOLO_STACKTRACE"
+	// EnvSeed defines the base58 encoded seed of the L1 ledger and of the key pairs. Overrides WithSeed.
+	EnvSeed = "NOTSOLO_SEED"
+	// EnvConservationCheck enables the token conservation check when set to a true value (see strconv.ParseBool). Overrides WithConservationCheck.
+	EnvConservationCheck = "NOTSOLO_CONSERVATIONCHECK"
+)
+
+// Option configures an instance created by New or NewPooled
+type Option func(*settings)
+
+// settings holds the configuration of a NotSolo instance. It is comparable, so it can be used to find pooled instances.
+type settings struct {
+	debug             bool
+	printStackTrace   bool
+	seed              string
+	logger            *logger.Logger
+	conservationCheck bool
+}
+
+// WithDebug enables debug logs of the VM
+func WithDebug() Option {
+	return func(settings *settings) {
+		settings.debug = true
+	}
+}
+
+// WithStackTrace enables stack traces in the logs
+func WithStackTrace() Option {
+	return func(settings *settings) {
+		settings.printStackTrace = true
+	}
+}
+
+// WithSeed defines the seed of the L1 ledger and of the key pairs, which makes the key pairs and their addresses reproducible.
+// Chain IDs and colors still change from run to run. A random seed is used if not defined or if 'seed' is nil.
+func WithSeed(seed *ed25519.Seed) Option {
+	return func(settings *settings) {
+		if seed == nil {
+			settings.seed = ""
+			return
+		}
+		settings.seed = base58.Encode(seed.Bytes())
+	}
+}
+
+// WithLogger makes solo log to 'logger'. WithDebug and WithStackTrace have no effect if a logger is defined.
+func WithLogger(logger *logger.Logger) Option {
+	return func(settings *settings) {
+		settings.logger = logger
+	}
+}
+
+// WithConservationCheck fails test if a request posted by the managers creates or destroys tokens. See conservationchecker.ConservationChecker.
+func WithConservationCheck() Option {
+	return func(settings *settings) {
+		settings.conservationCheck = true
+	}
+}
+
+// newSettings applies 'options' and then the environment variables, so a test run can be changed without changing code
+func newSettings(options ...Option) (settings, error) {
+	newSettings := settings{}
+	for _, option := range options {
+		option(&newSettings)
+	}
+
+	var err error
+	if value, ok := os.LookupEnv(EnvDebug); ok {
+		if newSettings.debug, err = strconv.ParseBool(value); err != nil {
+			return newSettings, err
+		}
+	}
+
+	if value, ok := os.LookupEnv(EnvStackTrace); ok {
+		if newSettings.printStackTrace, err = strconv.ParseBool(value); err != nil {
+			return newSettings, err
+		}
+	}
+
+	if value, ok := os.LookupEnv(EnvConservationCheck); ok {
+		if newSettings.conservationCheck, err = strconv.ParseBool(value); err != nil {
+			return newSettings, err
+		}
+	}
+
+	if value, ok := os.LookupEnv(EnvSeed); ok {
+		newSettings.seed = value
+	}
+
+	if newSettings.seed != "" {
+		if err = validateSeed(newSettings.seed); err != nil {
+			return newSettings, err
+		}
+	}
+
+	return newSettings, nil
+}
+
+// validateSeed returns an error if 'seed' is not the base58 encoding of ed25519.SeedSize bytes
+func validateSeed(seed string) error {
+	seedBytes, err := base58.Decode(seed)
+	if err != nil {
+		return fmt.Errorf("seed %q is not valid base58: %w", seed, err)
+	}
+	if len(seedBytes) != ed25519.SeedSize {
+		return fmt.Errorf("seed %q has %d bytes, expected %d", seed, len(seedBytes), ed25519.SeedSize)
+	}
+	return nil
+}
+
+// getSeed decodes the seed of the L1 ledger and of the key pairs. Returns nil if no seed is defined. The seed must have been validated
+// by newSettings.
+func (settings settings) getSeed() *ed25519.Seed {
+	if settings.seed == "" {
+		return nil
+	}
+	seedBytes, _ := base58.Decode(settings.seed)
+	return ed25519.NewSeed(seedBytes)
+}
@@ -0,0 +1,35 @@
+package notsolo
+
+import "sync"
+
+// instancePool keeps idle NotSolo instances, grouped by settings, to be reused by NewPooled
+type instancePool struct {
+	mutex sync.Mutex
+	idle  map[settings][]*NotSolo
+}
+
+var pool = &instancePool{idle: make(map[settings][]*NotSolo)}
+
+// get takes an idle instance with the specified settings out of the pool. Returns nil if there is none.
+func (instancePool *instancePool) get(settings settings) *NotSolo {
+	instancePool.mutex.Lock()
+	defer instancePool.mutex.Unlock()
+
+	idle := instancePool.idle[settings]
+	idleCount := len(idle)
+	if idleCount == 0 {
+		return nil
+	}
+
+	notSolo := idle[idleCount-1]
+	instancePool.idle[settings] = idle[:idleCount-1]
+	return notSolo
+}
+
+// put makes an instance available to the next call to get
+func (instancePool *instancePool) put(notSolo *NotSolo) {
+	instancePool.mutex.Lock()
+	defer instancePool.mutex.Unlock()
+
+	instancePool.idle[notSolo.settings] = append(instancePool.idle[notSolo.settings], notSolo)
+}
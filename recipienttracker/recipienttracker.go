@@ -0,0 +1,46 @@
+package recipienttracker
+
+import (
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/wasp/packages/solo"
+)
+
+// RecipientTracker implements RequestObserver. It remembers the L1 addresses of the requesters and receivers of the requests and
+// transfers posted through the managers, so that they are counted after the request even if they belong to no key pair of the managers.
+// Addresses which only receive tokens from contracts, without requesting, are not known.
+type RecipientTracker struct {
+	recipients []ledgerstate.Address
+	isTracked  map[string]bool
+}
+
+// New instantiates a recipient tracker
+func New() *RecipientTracker {
+	recipientTracker := &RecipientTracker{isTracked: make(map[string]bool)}
+	return recipientTracker
+}
+
+// Dispose implements Disposable for RecipientTracker. Forgets the recipients.
+func (recipientTracker *RecipientTracker) Dispose() {
+	recipientTracker.recipients = nil
+	recipientTracker.isTracked = make(map[string]bool)
+}
+
+// GetRecipients returns the addresses of the requesters and receivers of all requests posted so far
+func (recipientTracker *RecipientTracker) GetRecipients() []ledgerstate.Address {
+	return append([]ledgerstate.Address(nil), recipientTracker.recipients...)
+}
+
+// BeforeRequest implements RequestObserver for RecipientTracker. Remembers 'addresses'.
+func (recipientTracker *RecipientTracker) BeforeRequest(chain *solo.Chain, addresses []ledgerstate.Address) {
+	for _, address := range addresses {
+		if recipientTracker.isTracked[address.Base58()] {
+			continue
+		}
+		recipientTracker.isTracked[address.Base58()] = true
+		recipientTracker.recipients = append(recipientTracker.recipients, address)
+	}
+}
+
+// AfterRequest implements RequestObserver for RecipientTracker
+func (recipientTracker *RecipientTracker) AfterRequest(chain *solo.Chain) {
+}
@@ -0,0 +1,112 @@
+package requestmanager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// ErrRequestNotProcessed is returned by RequestHandle.Result while the request waits in the backlog of its chain
+var ErrRequestNotProcessed = errors.New("request not processed yet")
+
+// RequestHandle refers to a request posted with PostAsync
+type RequestHandle struct {
+	ID        iscp.RequestID
+	chain     *solo.Chain
+	requester ledgerstate.Address
+}
+
+// IsProcessed returns whether the chain has processed the request
+func (handle *RequestHandle) IsProcessed() bool {
+	return handle.chain.IsRequestProcessed(handle.ID)
+}
+
+// BlockIndex returns the index of the block in which the chain processed the request, or ErrRequestNotProcessed if the request is still
+// in the backlog. Requests with the same block index were processed in the same batch.
+func (handle *RequestHandle) BlockIndex() (uint32, error) {
+	_, blockIndex, _, ok := handle.chain.GetRequestReceipt(handle.ID)
+	if !ok {
+		return 0, ErrRequestNotProcessed
+	}
+	return blockIndex, nil
+}
+
+// Result returns the error of the contract, if it failed, or ErrRequestNotProcessed if the request is still in the backlog.
+// Solo does not keep the values returned by requests processed from the backlog. Post requests with Post to read their values.
+func (handle *RequestHandle) Result() error {
+	receipt, _, _, ok := handle.chain.GetRequestReceipt(handle.ID)
+	if !ok {
+		return ErrRequestNotProcessed
+	}
+	if receipt.Error != "" {
+		return newContractError(errors.New(receipt.Error))
+	}
+	return nil
+}
+
+// PostAsync creates a request as requester or, if not specified, as the chain originator and adds it to the backlog of the chain without
+// waiting for it to be processed. 1 IOTA is attached to call. Use NewRequest(...).PostAsync to attach other tokens.
+// Solo's backlog loop decides which requests share a block, so requests posted in a row may or may not be processed in the same batch
+// (see RequestHandle.BlockIndex). Returns a handle to the request or an error.
+func (requestManager *RequestManager) PostAsync(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) (*RequestHandle, error) {
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).PostAsync()
+}
+
+// MustPostAsync creates a request as requester or, if not specified, as the chain originator and adds it to the backlog of the chain without
+// waiting for it to be processed. 1 IOTA is attached to call. Fails test if the request could not be posted.
+func (requestManager *RequestManager) MustPostAsync(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) *RequestHandle {
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).MustPostAsync()
+}
+
+// WaitForRequestsThrough waits until 'numRequests' requests, counted since the chain was created, went through the backlog of the chain.
+// Returns false if that did not happen within 'maxWait' (solo's default if not specified). The request observers are notified around the wait,
+// so requests posted with PostAsync are checked once they are processed.
+func (requestManager *RequestManager) WaitForRequestsThrough(chain *solo.Chain, numRequests int, maxWait ...time.Duration) bool {
+	isThrough := false
+	_ = requestManager.observers.Notify(chain, requestManager.getPendingRequesters(chain), func() error {
+		isThrough = chain.WaitForRequestsThrough(numRequests, maxWait...)
+		return nil
+	})
+	return isThrough
+}
+
+// RunBacklog waits until every request the backlog of 'chain' received so far, including those posted with PostAsync, went through it.
+// Fails test if that does not happen within 'maxWait' (solo's default if not specified) or if a request posted with PostAsync was not processed.
+// The request observers are notified around the wait, so the requests are checked once they are processed.
+func (requestManager *RequestManager) RunBacklog(chain *solo.Chain, maxWait ...time.Duration) {
+	numRequests := chain.MempoolInfo().InBufCounter
+	isThrough := requestManager.WaitForRequestsThrough(chain, numRequests, maxWait...)
+	require.True(requestManager.env.T, isThrough, "Backlog of chain %s was not processed", chain.Name)
+	for _, handle := range requestManager.pending {
+		if handle.chain == chain {
+			require.True(requestManager.env.T, handle.IsProcessed(), "Request %s to chain %s was not processed", handle.ID, chain.Name)
+		}
+	}
+
+	// Forget the processed requests of 'chain'
+	var pending []*RequestHandle
+	for _, handle := range requestManager.pending {
+		if handle.chain != chain {
+			pending = append(pending, handle)
+		}
+	}
+	requestManager.pending = pending
+}
+
+// getPendingRequesters returns the L1 addresses of the requesters of the requests posted with PostAsync to 'chain'
+func (requestManager *RequestManager) getPendingRequesters(chain *solo.Chain) []ledgerstate.Address {
+	var requesters []ledgerstate.Address
+	for _, handle := range requestManager.pending {
+		if handle.chain == chain {
+			requesters = append(requesters, handle.requester)
+		}
+	}
+	return requesters
+}
@@ -0,0 +1,188 @@
+package requestmanager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// ErrorKind is the cause of a failed request or view
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is the zero value of ErrorKind. Errors of failed requests and views are never of this kind.
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindInvalidParams is the kind of errors caused by parameters which could not be decoded
+	ErrorKindInvalidParams
+	// ErrorKindNotFound is the kind of errors caused by an unknown contract or function
+	ErrorKindNotFound
+	// ErrorKindUnauthorized is the kind of errors caused by a caller without permission
+	ErrorKindUnauthorized
+	// ErrorKindInsufficientFunds is the kind of errors caused by missing tokens, in L1 or in the chain
+	ErrorKindInsufficientFunds
+	// ErrorKindPanic is the kind of errors caused by a panic in the contract or by any other failure which is not of another kind
+	ErrorKindPanic
+)
+
+// errorKindPhrases maps kinds of errors to the phrases with which wasp reports them, in the order in which they are verified.
+// Only phrases of wasp itself are listed, so that messages of contracts (e.g. "item not found") are not mistaken for errors of wasp.
+// Errors which match no phrase are of kind ErrorKindPanic.
+var errorKindPhrases = []struct {
+	kind    ErrorKind
+	phrases []string
+}{
+	{ErrorKindNotFound, []string{"entry point not found"}},
+	{ErrorKindUnauthorized, []string{"unauthorized"}},
+	{ErrorKindInvalidParams, []string{"cannot decode", "can't decode", "wrong data length"}},
+	{ErrorKindInsufficientFunds, []string{"not enough funds", "insufficient funds"}},
+}
+
+// String implements fmt.Stringer for ErrorKind
+func (kind ErrorKind) String() string {
+	switch kind {
+	case ErrorKindInvalidParams:
+		return "invalid params"
+	case ErrorKindNotFound:
+		return "not found"
+	case ErrorKindUnauthorized:
+		return "unauthorized"
+	case ErrorKindInsufficientFunds:
+		return "insufficient funds"
+	case ErrorKindPanic:
+		return "panic"
+	default:
+		return "unknown"
+	}
+}
+
+// ContractError is the error of a failed request or view, classified by its cause.
+// errors.Is(err, ErrUnauthorized) and alike verify the kind of the error.
+type ContractError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+var (
+	// ErrInvalidParams matches contract errors of kind ErrorKindInvalidParams
+	ErrInvalidParams = &ContractError{Kind: ErrorKindInvalidParams}
+	// ErrNotFound matches contract errors of kind ErrorKindNotFound
+	ErrNotFound = &ContractError{Kind: ErrorKindNotFound}
+	// ErrUnauthorized matches contract errors of kind ErrorKindUnauthorized
+	ErrUnauthorized = &ContractError{Kind: ErrorKindUnauthorized}
+	// ErrContractInsufficientFunds matches contract errors of kind ErrorKindInsufficientFunds
+	ErrContractInsufficientFunds = &ContractError{Kind: ErrorKindInsufficientFunds}
+	// ErrPanic matches contract errors of kind ErrorKindPanic
+	ErrPanic = &ContractError{Kind: ErrorKindPanic}
+)
+
+// Error implements error for ContractError
+func (contractError *ContractError) Error() string {
+	if contractError.Err == nil {
+		return contractError.Kind.String()
+	}
+	return contractError.Err.Error()
+}
+
+// Unwrap returns the error returned by solo
+func (contractError *ContractError) Unwrap() error {
+	return contractError.Err
+}
+
+// Is returns whether 'target' is a ContractError of the same kind
+func (contractError *ContractError) Is(target error) bool {
+	targetContractError, ok := target.(*ContractError)
+	return ok && targetContractError.Kind == contractError.Kind
+}
+
+// newContractError classifies 'err', returned by wasp, by the phrases of its message. Returns nil if 'err' is nil.
+func newContractError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, errorKind := range errorKindPhrases {
+		for _, phrase := range errorKind.phrases {
+			if strings.Contains(message, phrase) {
+				return &ContractError{Kind: errorKind.kind, Err: err}
+			}
+		}
+	}
+	return &ContractError{Kind: ErrorKindPanic, Err: err}
+}
+
+// checkContract returns an error of kind ErrorKindNotFound if 'chain' has no contract called 'contractName'
+func checkContract(chain *solo.Chain, contractName string) error {
+	if contractRecord, err := chain.FindContract(contractName); err != nil || contractRecord == nil {
+		return &ContractError{Kind: ErrorKindNotFound, Err: fmt.Errorf("contract %s not found in chain %s", contractName, chain.Name)}
+	}
+	return nil
+}
+
+// checkL1Funds returns an error of kind ErrorKindInsufficientFunds, which wraps l1ledger.ErrInsufficientFunds, if the key pair does not
+// hold 'balances' in L1
+func (requestManager *RequestManager) checkL1Funds(keyPair *ed25519.KeyPair, balances colored.Balances) error {
+	address := ledgerstate.NewED25519Address(keyPair.PublicKey)
+	for color, balance := range balances {
+		if requestManager.env.GetAddressBalance(address, color) < balance {
+			return &ContractError{Kind: ErrorKindInsufficientFunds, Err: fmt.Errorf("%s holds less than %d of color %s in L1: %w",
+				requestManager.keyPairManager.NameOf(keyPair), balance, requestManager.keyPairManager.NameOfColor(color), l1ledger.ErrInsufficientFunds)}
+		}
+	}
+	return nil
+}
+
+// requireErrorMatches verifies if 'err' matches 'expected', which is either a substring of the message (string), an error matched with
+// errors.Is (e.g. ErrUnauthorized) or a matcher (func(error) bool). Fails test otherwise.
+func (requestManager *RequestManager) requireErrorMatches(err error, expected interface{}, description string) {
+	require.Error(requestManager.env.T, err, "%s succeeded", description)
+
+	switch expected := expected.(type) {
+	case string:
+		require.Contains(requestManager.env.T, err.Error(), expected, "%s failed with an unexpected error", description)
+	case error:
+		require.ErrorIs(requestManager.env.T, err, expected, "%s failed with an unexpected error", description)
+	case func(error) bool:
+		require.True(requestManager.env.T, expected(err), "%s failed with an unexpected error: %s", description, err)
+	default:
+		require.FailNow(requestManager.env.T, fmt.Sprintf("Errors cannot be matched with %T", expected))
+	}
+}
+
+// MustPostFailWith creates a request to contract function in the chain as requester. The contract function is called with optional params.
+// 1 IOTA is necessary to process the request; use MustPostWithTransferFailWith or MustPostWithTransfersFailWith to attach other tokens.
+// Fails test if request succeeds or if its error does not match 'expected', which is either a substring of the message (string),
+// an error matched with errors.Is (e.g. ErrUnauthorized) or a matcher (func(error) bool).
+func (requestManager *RequestManager) MustPostFailWith(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string, functionName string,
+	expected interface{}, params ...interface{}) {
+	requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).MustFailWith(expected)
+}
+
+// MustPostWithTransferFailWith creates a request to contract function in the chain as requester. The contract function is called with optional params.
+// It attaches 'amount' of 'color' to call. Fails test if request succeeds or if its error does not match 'expected' (see MustPostFailWith).
+func (requestManager *RequestManager) MustPostWithTransferFailWith(requesterKeyPair *ed25519.KeyPair, color colored.Color, amount uint64,
+	chain *solo.Chain, contractName string, functionName string, expected interface{}, params ...interface{}) {
+	requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfer(color, amount).MustFailWith(expected)
+}
+
+// MustPostWithTransfersFailWith creates a request to contract function in the chain as requester. The contract function is called with optional params.
+// It attaches 'transfers' (any number of colors) to call. Fails test if request succeeds or if its error does not match 'expected' (see MustPostFailWith).
+func (requestManager *RequestManager) MustPostWithTransfersFailWith(requesterKeyPair *ed25519.KeyPair, transfers colored.Balances,
+	chain *solo.Chain, contractName string, functionName string, expected interface{}, params ...interface{}) {
+	requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfers(transfers).MustFailWith(expected)
+}
+
+// MustViewFailWith creates a view request. The contract view in the chain is called with optional params.
+// Fails test if request succeeds or if its error does not match 'expected' (see MustPostFailWith).
+func (requestManager *RequestManager) MustViewFailWith(chain *solo.Chain, contractName string, functionName string,
+	expected interface{}, params ...interface{}) {
+	_, err := requestManager.View(chain, contractName, functionName, params...)
+	description := fmt.Sprintf("View %s.%s", contractName, functionName)
+	requestManager.requireErrorMatches(err, expected, description)
+}
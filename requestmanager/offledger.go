@@ -0,0 +1,73 @@
+package requestmanager
+
+import (
+	"errors"
+
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/iscp/request"
+	"github.com/iotaledger/wasp/packages/iscp/requestargs"
+	"github.com/iotaledger/wasp/packages/kv/dict"
+	"github.com/iotaledger/wasp/packages/solo"
+)
+
+var (
+	// ErrRequestReplayed is returned when an off-ledger request identical to one the chain already processed is posted again
+	ErrRequestReplayed = errors.New("off-ledger request already processed")
+	// ErrInvalidSignature is returned when a signed off-ledger request was changed after it was signed
+	ErrInvalidSignature = errors.New("invalid signature of off-ledger request")
+)
+
+// SignedOffLedgerRequest is an off-ledger request signed by its requester, as a frontend would send it to a wasp node. See PostSignedOffLedger.
+type SignedOffLedgerRequest struct {
+	*request.OffLedger
+	requesterKeyPair *ed25519.KeyPair
+	contractName     string
+	functionName     string
+	args             dict.Dict
+}
+
+// NewSignedOffLedgerRequest builds an off-ledger request to contract function with 'nonce' and 'args', signed by the requester.
+// Changing the request after it is signed (e.g. with WithNonce) invalidates its signature, which can be verified with VerifySignature.
+func NewSignedOffLedgerRequest(requesterKeyPair *ed25519.KeyPair, contractName string, functionName string, nonce uint64,
+	args dict.Dict) *SignedOffLedgerRequest {
+	offLedgerRequest := request.NewOffLedger(iscp.Hn(contractName), iscp.Hn(functionName), requestargs.New(args)).WithNonce(nonce)
+	offLedgerRequest.Sign(requesterKeyPair)
+	return &SignedOffLedgerRequest{OffLedger: offLedgerRequest, requesterKeyPair: requesterKeyPair, contractName: contractName,
+		functionName: functionName, args: args}
+}
+
+// PostSignedOffLedger verifies the signature of 'signedRequest' and posts it to 'chain'. The chain is not involved in the verification:
+// solo neither verifies signatures nor accepts pre-built requests. NotSolo itself rejects requests whose signature is not valid with
+// ErrInvalidSignature, so they never reach the chain. A valid request is posted as a new request with the same requester, contract,
+// function, args and nonce, which solo signs again. Returns response as a Dict or an error.
+func (requestManager *RequestManager) PostSignedOffLedger(chain *solo.Chain, signedRequest *SignedOffLedgerRequest) (dict.Dict, error) {
+	if !signedRequest.VerifySignature() {
+		return nil, ErrInvalidSignature
+	}
+
+	params := make([]interface{}, 0, 2*len(signedRequest.args))
+	for key, value := range signedRequest.args {
+		params = append(params, string(key), value)
+	}
+	return requestManager.PostOffLedgerWithNonce(signedRequest.requesterKeyPair, signedRequest.Nonce(), chain, signedRequest.contractName,
+		signedRequest.functionName, params...)
+}
+
+// postOffLedger posts 'callParams' to 'chain' as requester or, if not specified, as the chain originator. Like the API of a wasp node, it rejects
+// requests identical to one the chain already processed (ErrRequestReplayed), which are the requests with a repeated nonce.
+func (requestManager *RequestManager) postOffLedger(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	callParams *solo.CallParams) (dict.Dict, error) {
+	if err := checkContract(chain, contractName); err != nil {
+		return nil, err
+	}
+	if requesterKeyPair == nil {
+		requesterKeyPair = chain.OriginatorKeyPair
+	}
+	if chain.IsRequestProcessed(callParams.NewRequestOffLedger(requesterKeyPair).ID()) {
+		return nil, ErrRequestReplayed
+	}
+
+	response, err := requestManager.observers.PostRequestOffLedger(chain, callParams, requesterKeyPair)
+	return response, newContractError(err)
+}
@@ -0,0 +1,153 @@
+package requestmanager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/kv/dict"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// Deltas are changes of balance by color
+type Deltas map[colored.Color]int64
+
+// Receipt describes a request processed by a chain and its side effects
+type Receipt struct {
+	RequestID  iscp.RequestID
+	BlockIndex uint32
+	// FeeColor, NominalOwnerFee and NominalValidatorFee are the fees of the contract, as set in the chain when the request was posted.
+	// The fees actually charged are part of AccountDeltas, in the accounts of the chain owner and of the validator fee target.
+	FeeColor            colored.Color
+	NominalOwnerFee     uint64
+	NominalValidatorFee uint64
+	// Duration is the time solo took to post and process the request. Wasp does not meter gas, so no gas is reported.
+	Duration time.Duration
+	Events   []string
+	Response dict.Dict
+	// L1Deltas are the changes of balance of the requester and of the chain in L1, by address in base58
+	L1Deltas map[string]Deltas
+	// AccountDeltas are the changes of balance of the accounts in the chain, by AgentID
+	AccountDeltas map[string]Deltas
+}
+
+// GetL1Delta returns the change of balance of 'color' of 'address' in L1 caused by the request
+func (receipt *Receipt) GetL1Delta(address ledgerstate.Address, color colored.Color) int64 {
+	return receipt.L1Deltas[address.Base58()][color]
+}
+
+// GetAccountDelta returns the change of balance of 'color' of the account of 'agentID' in the chain caused by the request
+func (receipt *Receipt) GetAccountDelta(agentID *iscp.AgentID, color colored.Color) int64 {
+	return receipt.AccountDeltas[agentID.String()][color]
+}
+
+// PostWithReceipt creates a request as requester or, if not specified, as the chain originator. 1 IOTA is necessary to process the request.
+// The contract function in the chain is called with optional params. Returns the receipt of the request, even if the request failed, and its error.
+func (requestManager *RequestManager) PostWithReceipt(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) (*Receipt, error) {
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).PostWithReceipt()
+}
+
+// MustPostWithReceipt creates a request to contract function in the chain as requester and returns its receipt.
+// 1 IOTA is necessary to process the request. Fails test if request fails.
+func (requestManager *RequestManager) MustPostWithReceipt(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) *Receipt {
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).MustPostWithReceipt()
+}
+
+// PostWithReceipt posts the request. Returns the receipt of the request, even if the request failed, and its error.
+// The request is not posted, and no receipt is returned, if it fails the verifications of Post.
+func (request *Request) PostWithReceipt() (*Receipt, error) {
+	if err := request.check(); err != nil {
+		return nil, err
+	}
+	chain := request.chain
+	requesterKeyPair := request.requesterKeyPair
+	if requesterKeyPair == nil {
+		requesterKeyPair = chain.OriginatorKeyPair
+	}
+	env := request.requestManager.env
+	addresses := []ledgerstate.Address{ledgerstate.NewED25519Address(requesterKeyPair.PublicKey), chain.ChainID.AsAddress()}
+
+	receipt := &Receipt{}
+	receipt.FeeColor, receipt.NominalOwnerFee, receipt.NominalValidatorFee = chain.GetFeeInfo(request.contractName)
+	l1BalancesBefore := getL1Balances(env, addresses)
+	accountBalancesBefore := getAccountBalances(chain)
+
+	start := time.Now()
+	transaction, response, err := request.requestManager.observers.PostRequestSyncTx(chain, request.callParams(), requesterKeyPair)
+	receipt.Duration = time.Since(start)
+	receipt.Response = response
+	if transaction == nil {
+		return nil, newContractError(err)
+	}
+
+	requests, requestsErr := env.RequestsForChain(transaction, chain.ChainID)
+	if requestsErr != nil || len(requests) == 0 {
+		return nil, newContractError(errors.New("request not found in transaction"))
+	}
+	receipt.RequestID = requests[0].ID()
+	_, receipt.BlockIndex, _, _ = chain.GetRequestReceipt(receipt.RequestID)
+	receipt.Events, _ = chain.GetEventsForRequest(receipt.RequestID)
+
+	receipt.L1Deltas = getDeltas(l1BalancesBefore, getL1Balances(env, addresses))
+	receipt.AccountDeltas = getDeltas(accountBalancesBefore, getAccountBalances(chain))
+	return receipt, newContractError(err)
+}
+
+// MustPostWithReceipt posts the request and returns its receipt. Fails test if request fails.
+func (request *Request) MustPostWithReceipt() *Receipt {
+	receipt, err := request.PostWithReceipt()
+	require.NoError(request.requestManager.env.T, err, "%s failed", request)
+	return receipt
+}
+
+// getL1Balances returns the balances of 'addresses' in L1, by address in base58
+func getL1Balances(env *solo.Solo, addresses []ledgerstate.Address) map[string]colored.Balances {
+	balances := make(map[string]colored.Balances, len(addresses))
+	for _, address := range addresses {
+		balances[address.Base58()] = env.GetAddressBalances(address)
+	}
+	return balances
+}
+
+// getAccountBalances returns the balances of all accounts of 'chain', by AgentID
+func getAccountBalances(chain *solo.Chain) map[string]colored.Balances {
+	balances := make(map[string]colored.Balances)
+	for _, agentID := range chain.GetAccounts() {
+		agentID := agentID
+		balances[agentID.String()] = chain.GetAccountBalance(&agentID)
+	}
+	return balances
+}
+
+// getDeltas returns the non-zero changes between 'before' and 'after', which are balances by owner
+func getDeltas(before map[string]colored.Balances, after map[string]colored.Balances) map[string]Deltas {
+	deltas := make(map[string]Deltas)
+	addDelta := func(owner string, color colored.Color) {
+		delta := int64(after[owner][color]) - int64(before[owner][color])
+		if delta == 0 {
+			return
+		}
+		if deltas[owner] == nil {
+			deltas[owner] = Deltas{}
+		}
+		deltas[owner][color] = delta
+	}
+
+	for owner, balances := range before {
+		for color := range balances {
+			addDelta(owner, color)
+		}
+	}
+	for owner, balances := range after {
+		for color := range balances {
+			addDelta(owner, color)
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,141 @@
+package requestmanager
+
+import (
+	"fmt"
+
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/kv/dict"
+	"github.com/iotaledger/wasp/packages/solo"
+	"github.com/stretchr/testify/require"
+)
+
+// Request builds a request to a contract function. The same request can be posted expecting either success or failure.
+type Request struct {
+	requestManager   *RequestManager
+	requesterKeyPair *ed25519.KeyPair
+	chain            *solo.Chain
+	contractName     string
+	functionName     string
+	params           []interface{}
+	transfers        colored.Balances
+}
+
+// NewRequest builds a request to contract function in the chain as requester or, if not specified, as the chain originator.
+// The contract function is called with optional params. 1 IOTA is attached to call, unless a transfer is defined.
+func (requestManager *RequestManager) NewRequest(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) *Request {
+	return &Request{
+		requestManager:   requestManager,
+		requesterKeyPair: requesterKeyPair,
+		chain:            chain,
+		contractName:     contractName,
+		functionName:     functionName,
+		params:           params,
+	}
+}
+
+// WithTransfer attaches 'amount' of 'color' to call, in addition to previously attached tokens
+func (request *Request) WithTransfer(color colored.Color, amount uint64) *Request {
+	return request.WithTransfers(colored.Balances{color: amount})
+}
+
+// WithTransfers attaches 'transfers' (any number of colors) to call, in addition to previously attached tokens
+func (request *Request) WithTransfers(transfers colored.Balances) *Request {
+	if request.transfers == nil {
+		request.transfers = colored.Balances{}
+	}
+	for color, amount := range transfers {
+		request.transfers[color] += amount
+	}
+	return request
+}
+
+// Post posts the request. Returns response as a Dict or an error. The request is not posted if the contract does not exist (ErrNotFound)
+// or if the requester does not hold the attached tokens in L1 (ErrContractInsufficientFunds, which wraps l1ledger.ErrInsufficientFunds).
+func (request *Request) Post() (dict.Dict, error) {
+	if err := request.check(); err != nil {
+		return nil, err
+	}
+	response, err := request.requestManager.observers.PostRequestSync(request.chain, request.callParams(), request.requesterKeyPair)
+	return response, newContractError(err)
+}
+
+// PostAsync posts the request to the backlog of the chain without waiting for it to be processed. Returns a handle to the request or
+// an error, with the same checks as Post.
+func (request *Request) PostAsync() (*RequestHandle, error) {
+	if err := request.check(); err != nil {
+		return nil, err
+	}
+	requesterKeyPair := request.requesterKeyPair
+	if requesterKeyPair == nil {
+		requesterKeyPair = request.chain.OriginatorKeyPair
+	}
+	transaction, requestID, err := request.chain.RequestFromParamsToLedger(request.callParams(), requesterKeyPair)
+	if err != nil {
+		return nil, newContractError(err)
+	}
+	request.requestManager.env.EnqueueRequests(transaction)
+
+	handle := &RequestHandle{ID: requestID, chain: request.chain, requester: ledgerstate.NewED25519Address(requesterKeyPair.PublicKey)}
+	request.requestManager.pending = append(request.requestManager.pending, handle)
+	return handle, nil
+}
+
+// check verifies, before the request is posted, that the contract exists and that the requester holds the attached tokens in L1
+func (request *Request) check() error {
+	if err := checkContract(request.chain, request.contractName); err != nil {
+		return err
+	}
+	requesterKeyPair := request.requesterKeyPair
+	if requesterKeyPair == nil {
+		requesterKeyPair = request.chain.OriginatorKeyPair
+	}
+	return request.requestManager.checkL1Funds(requesterKeyPair, request.getTransfers())
+}
+
+// getTransfers returns the tokens attached to call: the defined transfers or, if none is defined, 1 IOTA
+func (request *Request) getTransfers() colored.Balances {
+	if request.transfers != nil {
+		return request.transfers
+	}
+	return colored.Balances{colored.IOTA: 1}
+}
+
+// callParams returns the parameters of the request for solo
+func (request *Request) callParams() *solo.CallParams {
+	return solo.NewCallParams(request.contractName, request.functionName, request.params...).WithTransfers(request.getTransfers())
+}
+
+// MustPost posts the request. Fails test if request fails.
+func (request *Request) MustPost() dict.Dict {
+	response, err := request.Post()
+	require.NoError(request.requestManager.env.T, err, "%s failed", request)
+	return response
+}
+
+// MustPostAsync posts the request to the backlog of the chain. Fails test if the request could not be posted.
+func (request *Request) MustPostAsync() *RequestHandle {
+	handle, err := request.PostAsync()
+	require.NoError(request.requestManager.env.T, err, "%s could not be posted", request)
+	return handle
+}
+
+// MustFail posts the request. Fails test if request succeeds.
+func (request *Request) MustFail() {
+	_, err := request.Post()
+	require.Error(request.requestManager.env.T, err, "%s succeeded", request)
+}
+
+// MustFailWith posts the request. Fails test if request succeeds or if its error does not match 'expected', which is either a substring
+// of the message (string), an error matched with errors.Is (e.g. ErrUnauthorized) or a matcher (func(error) bool).
+func (request *Request) MustFailWith(expected interface{}) {
+	_, err := request.Post()
+	request.requestManager.requireErrorMatches(err, expected, request.String())
+}
+
+// String implements fmt.Stringer for Request. Describes the request in messages.
+func (request *Request) String() string {
+	return fmt.Sprintf("Request of %s to %s.%s", request.requestManager.keyPairManager.NameOf(request.requesterKeyPair), request.contractName, request.functionName)
+}
@@ -1,6 +1,10 @@
 package requestmanager
 
 import (
+	"time"
+
+	"github.com/brunoamancio/NotSolo/keypairmanager"
+	"github.com/brunoamancio/NotSolo/requestobserver"
 	"github.com/iotaledger/hive.go/crypto/ed25519"
 	"github.com/iotaledger/wasp/packages/iscp/colored"
 	"github.com/iotaledger/wasp/packages/kv/dict"
@@ -10,44 +14,106 @@ import (
 
 // RequestManager manipulates requests
 type RequestManager struct {
-	env *solo.Solo
+	env            *solo.Solo
+	keyPairManager *keypairmanager.KeyPairManager
+	observers      requestobserver.Observers
+	pending        []*RequestHandle
 }
 
 // New instantiates a request manager
-func New(env *solo.Solo) *RequestManager {
-	requestManager := &RequestManager{env: env}
+func New(env *solo.Solo, keyPairManager *keypairmanager.KeyPairManager) *RequestManager {
+	requestManager := &RequestManager{env: env, keyPairManager: keyPairManager}
 	return requestManager
 }
 
+// Dispose implements Disposable for RequestManager. Forgets requests posted with PostAsync.
+func (requestManager *RequestManager) Dispose() {
+	requestManager.pending = nil
+}
+
+// AddRequestObserver makes 'observer' be notified of every request posted by the manager
+func (requestManager *RequestManager) AddRequestObserver(observer requestobserver.RequestObserver) {
+	requestManager.observers.Add(observer)
+}
+
 // Post creates a request as requester or, if not specified, as the chain originator. 1 IOTA is necessary to process the request.
 // The contract function in the chain is called with optional params.
 // Returns response as a Dict or an error.
 func (requestManager *RequestManager) Post(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
 	functionName string, params ...interface{}) (dict.Dict, error) {
-	response, err := post(false, colored.Color{}, 0, requesterKeyPair, chain, contractName, functionName, params...)
-	return response, err
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).Post()
 }
 
 // PostWithTransfer creates a request as requester or, if not specified, as the chain originator. The contract function in the chain is called with optional params.
 // It attaches 'amount' of 'color' to call. Returns response as a Dict or an error.
 func (requestManager *RequestManager) PostWithTransfer(requesterKeyPair *ed25519.KeyPair, color colored.Color, amount uint64,
 	chain *solo.Chain, contractName string, functionName string, params ...interface{}) (dict.Dict, error) {
-	response, err := post(true, color, amount, requesterKeyPair, chain, contractName, functionName, params...)
-	return response, err
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfer(color, amount).Post()
 }
 
-// 1 IOTA is necessary to process the request if 'withTransfer' is 'false'.
-func post(withTransfer bool, color colored.Color, amount uint64,
-	requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+// PostWithTransfers creates a request as requester or, if not specified, as the chain originator. The contract function in the chain is called with optional params.
+// It attaches 'transfers' (any number of colors) to call. Returns response as a Dict or an error.
+func (requestManager *RequestManager) PostWithTransfers(requesterKeyPair *ed25519.KeyPair, transfers colored.Balances,
+	chain *solo.Chain, contractName string, functionName string, params ...interface{}) (dict.Dict, error) {
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfers(transfers).Post()
+}
+
+// PostOffLedger creates an off-ledger request as requester or, if not specified, as the chain originator. The request is signed by the
+// requester and sent directly to the chain, so no transaction is added to L1. Its nonce is the current time in nanoseconds, so that
+// identical calls are different requests. The contract function in the chain is called with optional params.
+// Returns response as a Dict or an error. The request is not posted if the contract does not exist (ErrNotFound).
+func (requestManager *RequestManager) PostOffLedger(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
 	functionName string, params ...interface{}) (dict.Dict, error) {
-	request := solo.NewCallParams(contractName, functionName, params...)
-	if withTransfer {
-		request = request.WithTransfer(color, amount)
-	} else {
-		request = request.WithTransfer(colored.IOTA, uint64(1))
-	}
-	response, err := chain.PostRequestSync(request, requesterKeyPair)
-	return response, err
+	callParams := solo.NewCallParams(contractName, functionName, params...).WithNonce(uint64(time.Now().UnixNano()))
+	return requestManager.postOffLedger(requesterKeyPair, chain, contractName, callParams)
+}
+
+// PostOffLedgerWithNonce creates an off-ledger request as requester with 'nonce', instead of the current time.
+// Nonces may arrive out of order within a window: once the greatest nonce of the requester's previous off-ledger requests reaches
+// wasp's OffLedgerNonceStrictOrderTolerance (10000), the chain rejects nonces at or below that greatest nonce minus the tolerance.
+// Reusing a nonce for the same call repeats a processed request, which is rejected with ErrRequestReplayed.
+// The contract function in the chain is called with optional params. Returns response as a Dict or an error.
+func (requestManager *RequestManager) PostOffLedgerWithNonce(requesterKeyPair *ed25519.KeyPair, nonce uint64, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) (dict.Dict, error) {
+	callParams := solo.NewCallParams(contractName, functionName, params...).WithNonce(nonce)
+	return requestManager.postOffLedger(requesterKeyPair, chain, contractName, callParams)
+}
+
+// MustPostOffLedger creates an off-ledger request to contract function in the chain as requester. No transaction is added to L1.
+// Fails test if request fails.
+func (requestManager *RequestManager) MustPostOffLedger(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) dict.Dict {
+	response, err := requestManager.PostOffLedger(requesterKeyPair, chain, contractName, functionName, params...)
+	require.NoError(requestManager.env.T, err, "Off-ledger request of %s to %s.%s failed", requestManager.keyPairManager.NameOf(requesterKeyPair),
+		contractName, functionName)
+	return response
+}
+
+// MustPostOffLedgerWithNonce creates an off-ledger request to contract function in the chain as requester with 'nonce'.
+// No transaction is added to L1. Fails test if request fails.
+func (requestManager *RequestManager) MustPostOffLedgerWithNonce(requesterKeyPair *ed25519.KeyPair, nonce uint64, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) dict.Dict {
+	response, err := requestManager.PostOffLedgerWithNonce(requesterKeyPair, nonce, chain, contractName, functionName, params...)
+	require.NoError(requestManager.env.T, err, "Off-ledger request of %s to %s.%s with nonce %d failed", requestManager.keyPairManager.NameOf(requesterKeyPair),
+		contractName, functionName, nonce)
+	return response
+}
+
+// MustPostOffLedgerFail creates an off-ledger request to contract function in the chain as requester. Fails test if request succeeds.
+func (requestManager *RequestManager) MustPostOffLedgerFail(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) {
+	_, err := requestManager.PostOffLedger(requesterKeyPair, chain, contractName, functionName, params...)
+	require.Error(requestManager.env.T, err, "Off-ledger request of %s to %s.%s succeeded", requestManager.keyPairManager.NameOf(requesterKeyPair),
+		contractName, functionName)
+}
+
+// MustPostOffLedgerWithNonceFail creates an off-ledger request to contract function in the chain as requester with 'nonce'.
+// Fails test if request succeeds.
+func (requestManager *RequestManager) MustPostOffLedgerWithNonceFail(requesterKeyPair *ed25519.KeyPair, nonce uint64, chain *solo.Chain, contractName string,
+	functionName string, params ...interface{}) {
+	_, err := requestManager.PostOffLedgerWithNonce(requesterKeyPair, nonce, chain, contractName, functionName, params...)
+	require.Error(requestManager.env.T, err, "Off-ledger request of %s to %s.%s with nonce %d succeeded", requestManager.keyPairManager.NameOf(requesterKeyPair),
+		contractName, functionName, nonce)
 }
 
 // MustPost creates a request to contract function in the chain as requester.
@@ -55,49 +121,62 @@ func post(withTransfer bool, color colored.Color, amount uint64,
 // Fails test if request fails.
 func (requestManager *RequestManager) MustPost(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string,
 	functionName string, params ...interface{}) dict.Dict {
-	response, err := requestManager.PostWithTransfer(requesterKeyPair, colored.IOTA, 1, chain, contractName, functionName, params...)
-	require.NoError(requestManager.env.T, err)
-	return response
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).MustPost()
 }
 
 // MustPostWithTransfer creates a request to contract function in the chain as requester.
 // It attaches 'amount' of 'color' to call. Fails test if request fails.
 func (requestManager *RequestManager) MustPostWithTransfer(requesterKeyPair *ed25519.KeyPair, color colored.Color, amount uint64,
 	chain *solo.Chain, contractName string, functionName string, params ...interface{}) dict.Dict {
-	response, err := requestManager.PostWithTransfer(requesterKeyPair, color, amount, chain, contractName, functionName, params...)
-	require.NoError(requestManager.env.T, err)
-	return response
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfer(color, amount).MustPost()
+}
+
+// MustPostWithTransfers creates a request to contract function in the chain as requester.
+// It attaches 'transfers' (any number of colors) to call. Fails test if request fails.
+func (requestManager *RequestManager) MustPostWithTransfers(requesterKeyPair *ed25519.KeyPair, transfers colored.Balances,
+	chain *solo.Chain, contractName string, functionName string, params ...interface{}) dict.Dict {
+	return requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfers(transfers).MustPost()
 }
 
-// MustPostFail creates a request to contract function in the chain as requester. Fails test if request succeeds.
-func (requestManager *RequestManager) MustPostFail(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string, functionName string) {
-	_, err := requestManager.Post(requesterKeyPair, chain, contractName, functionName)
-	require.Error(requestManager.env.T, err)
+// MustPostFail creates a request to contract function in the chain as requester. The contract function is called with optional params.
+// 1 IOTA is necessary to process the request. Fails test if request succeeds.
+func (requestManager *RequestManager) MustPostFail(requesterKeyPair *ed25519.KeyPair, chain *solo.Chain, contractName string, functionName string,
+	params ...interface{}) {
+	requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).MustFail()
 }
 
-// MustPostWithTransferFail creates a request to contract function in the chain as requester.
+// MustPostWithTransferFail creates a request to contract function in the chain as requester. The contract function is called with optional params.
 // It attaches 'amount' of 'color' to call. Fails test if request succeeds.
 func (requestManager *RequestManager) MustPostWithTransferFail(requesterKeyPair *ed25519.KeyPair,
 	color colored.Color, amount uint64,
-	chain *solo.Chain, contractName string, functionName string) {
-	_, err := requestManager.PostWithTransfer(requesterKeyPair, color, amount, chain, contractName, functionName)
-	require.Error(requestManager.env.T, err)
+	chain *solo.Chain, contractName string, functionName string, params ...interface{}) {
+	requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfer(color, amount).MustFail()
+}
+
+// MustPostWithTransfersFail creates a request to contract function in the chain as requester. The contract function is called with optional params.
+// It attaches 'transfers' (any number of colors) to call. Fails test if request succeeds.
+func (requestManager *RequestManager) MustPostWithTransfersFail(requesterKeyPair *ed25519.KeyPair, transfers colored.Balances,
+	chain *solo.Chain, contractName string, functionName string, params ...interface{}) {
+	requestManager.NewRequest(requesterKeyPair, chain, contractName, functionName, params...).WithTransfers(transfers).MustFail()
 }
 
 // View creates a view request. The contract view in the chain is called with optional params.
 // Returns response as a Dict or an error.
 func (requestManager *RequestManager) View(chain *solo.Chain, contractName string,
 	functionName string, params ...interface{}) (dict.Dict, error) {
+	if err := checkContract(chain, contractName); err != nil {
+		return nil, err
+	}
 	response, err := chain.CallView(contractName, functionName, params...)
-	return response, err
+	return response, newContractError(err)
 }
 
 // MustView creates a view request. The contract view in the chain is called with optional params.
 // Returns response as a Dict. Fails test on error.
 func (requestManager *RequestManager) MustView(chain *solo.Chain, contractName string,
 	functionName string, params ...interface{}) dict.Dict {
-	response, err := chain.CallView(contractName, functionName, params...)
-	require.NoError(requestManager.env.T, err)
+	response, err := requestManager.View(chain, contractName, functionName, params...)
+	require.NoError(requestManager.env.T, err, "View %s.%s failed", contractName, functionName)
 	return response
 }
 
@@ -105,6 +184,6 @@ func (requestManager *RequestManager) MustView(chain *solo.Chain, contractName s
 // Fails test if request succeeds.
 func (requestManager *RequestManager) MustViewFail(chain *solo.Chain, contractName string,
 	functionName string, params ...interface{}) {
-	_, err := chain.CallView(contractName, functionName, params...)
-	require.Error(requestManager.env.T, err)
+	_, err := requestManager.View(chain, contractName, functionName, params...)
+	require.Error(requestManager.env.T, err, "View %s.%s succeeded", contractName, functionName)
 }
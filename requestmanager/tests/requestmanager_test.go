@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"testing"
+
+	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/brunoamancio/NotSolo/l1ledger"
+	"github.com/brunoamancio/NotSolo/requestmanager"
+	"github.com/iotaledger/wasp/packages/hashing"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/kv/dict"
+	"github.com/iotaledger/wasp/packages/vm/core/accounts"
+	"github.com/iotaledger/wasp/packages/vm/core/governance"
+	"github.com/iotaledger/wasp/packages/vm/core/root"
+	"github.com/iotaledger/wasp/packages/vm/vmcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PostAsync(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+
+	// Post several requests to the backlog, one of which fails
+	handles := []*requestmanager.RequestHandle{
+		notSolo.Request.MustPostAsync(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name),
+		notSolo.Request.MustPostAsync(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name),
+		notSolo.Request.MustPostAsync(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name),
+	}
+	failingHandle := notSolo.Request.MustPostAsync(requesterKeyPair, chain, accounts.Contract.Name, "nonExistingFunction")
+
+	// Process the backlog. Solo decides how the requests are split into blocks.
+	notSolo.Request.RunBacklog(chain)
+	for _, handle := range handles {
+		require.NoError(t, handle.Result())
+		blockIndex, err := handle.BlockIndex()
+		require.NoError(t, err)
+		require.Greater(t, blockIndex, uint32(0))
+	}
+	err := failingHandle.Result()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, requestmanager.ErrRequestNotProcessed)
+	require.True(t, failingHandle.IsProcessed())
+
+	// Requests built with NewRequest carry their transfer to the backlog and are checked like posted requests
+	balanceTracker := notSolo.TrackBalances(requesterKeyPair, chain)
+	handle := notSolo.Request.NewRequest(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name).
+		WithTransfer(colored.IOTA, 100).MustPostAsync()
+	notSolo.Request.RunBacklog(chain)
+	require.NoError(t, handle.Result())
+	balanceTracker.RequireL1Delta(requesterKeyPair, colored.IOTA, -100)
+	balanceTracker.RequireChainDelta(requesterKeyPair, chain, colored.IOTA, 100)
+
+	_, err = notSolo.Request.PostAsync(requesterKeyPair, chain, "nonExistingContract", accounts.FuncDeposit.Name)
+	require.ErrorIs(t, err, requestmanager.ErrNotFound)
+}
+
+func Test_PostOffLedgerReplay(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with funds in the chain, since off-ledger requests carry no tokens from L1
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	requesterAgentID := notSolo.KeyPair.MustGetAgentID(requesterKeyPair)
+	notSolo.L1.MustTransferToChainToSelf(requesterKeyPair, chain, colored.IOTA, 100)
+	l1Balance := notSolo.L1.GetL1Balance(requesterKeyPair, colored.IOTA)
+
+	// Withdraw the account to L1 with an off-ledger request. No transaction of the requester is added to L1.
+	const nonce = uint64(1_000_000)
+	notSolo.Request.MustPostOffLedgerWithNonce(requesterKeyPair, nonce, chain, accounts.Contract.Name, accounts.FuncWithdraw.Name)
+	notSolo.L1.RequireBalance(requesterKeyPair, colored.IOTA, l1Balance+100)
+	notSolo.Chain.RequireAgentBalance(&requesterAgentID, chain, colored.IOTA, 0)
+
+	// Replaying the withdrawal with the same nonce is rejected and withdraws nothing
+	notSolo.L1.MustTransferToChainToSelf(requesterKeyPair, chain, colored.IOTA, 100)
+	balanceTracker := notSolo.TrackBalances(requesterKeyPair, chain)
+	_, err := notSolo.Request.PostOffLedgerWithNonce(requesterKeyPair, nonce, chain, accounts.Contract.Name, accounts.FuncWithdraw.Name)
+	require.ErrorIs(t, err, requestmanager.ErrRequestReplayed)
+	balanceTracker.RequireL1Delta(requesterKeyPair, colored.IOTA, 0)
+	balanceTracker.RequireChainDelta(requesterKeyPair, chain, colored.IOTA, 0)
+
+	// Smaller nonces are tolerated down to the greatest nonce minus OffLedgerNonceStrictOrderTolerance, exclusive
+	notSolo.Request.MustPostOffLedgerWithNonceFail(requesterKeyPair, nonce-vmcontext.OffLedgerNonceStrictOrderTolerance, chain,
+		accounts.Contract.Name, accounts.FuncDeposit.Name)
+	notSolo.Request.MustPostOffLedgerWithNonce(requesterKeyPair, nonce-vmcontext.OffLedgerNonceStrictOrderTolerance+1, chain,
+		accounts.Contract.Name, accounts.FuncDeposit.Name)
+	balanceTracker.RequireL1Delta(requesterKeyPair, colored.IOTA, 0)
+	balanceTracker.RequireChainDelta(requesterKeyPair, chain, colored.IOTA, 0)
+}
+
+func Test_PostOffLedger_IdenticalCalls(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with funds in the chain
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	notSolo.L1.MustTransferToChainToSelf(requesterKeyPair, chain, colored.IOTA, 100)
+
+	// Identical calls without a nonce are different requests. None of them is rejected as a replay.
+	for i := 0; i < 3; i++ {
+		_, err := notSolo.Request.PostOffLedger(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name)
+		require.NotErrorIs(t, err, requestmanager.ErrRequestReplayed)
+		require.NoError(t, err)
+	}
+}
+
+func Test_PostSignedOffLedger(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with funds in the chain
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	notSolo.L1.MustTransferToChainToSelf(requesterKeyPair, chain, colored.IOTA, 100)
+	l1Balance := notSolo.L1.GetL1Balance(requesterKeyPair, colored.IOTA)
+
+	// A signed request has a valid signature. Tampering with it after signing invalidates the signature.
+	tamperedRequest := requestmanager.NewSignedOffLedgerRequest(requesterKeyPair, accounts.Contract.Name, accounts.FuncWithdraw.Name, 1, dict.New())
+	require.True(t, tamperedRequest.VerifySignature())
+	tamperedRequest.WithNonce(2)
+	require.False(t, tamperedRequest.VerifySignature())
+
+	// The tampered request is rejected and changes nothing
+	balanceTracker := notSolo.TrackBalances(requesterKeyPair, chain)
+	_, err := notSolo.Request.PostSignedOffLedger(chain, tamperedRequest)
+	require.ErrorIs(t, err, requestmanager.ErrInvalidSignature)
+	require.False(t, chain.IsRequestProcessed(tamperedRequest.ID()))
+	balanceTracker.RequireL1Delta(requesterKeyPair, colored.IOTA, 0)
+	balanceTracker.RequireChainDelta(requesterKeyPair, chain, colored.IOTA, 0)
+
+	// A request with a valid signature withdraws the account
+	signedRequest := requestmanager.NewSignedOffLedgerRequest(requesterKeyPair, accounts.Contract.Name, accounts.FuncWithdraw.Name, 3, dict.New())
+	_, err = notSolo.Request.PostSignedOffLedger(chain, signedRequest)
+	require.NoError(t, err)
+	notSolo.L1.RequireBalance(requesterKeyPair, colored.IOTA, l1Balance+100)
+}
+
+func Test_PostWithReceipt(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	requesterAgentID := notSolo.KeyPair.MustGetAgentID(requesterKeyPair)
+
+	// Post a request which deposits 1 IOTA in the requester's account
+	receipt := notSolo.Request.MustPostWithReceipt(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name)
+	require.NotZero(t, receipt.BlockIndex)
+	require.Equal(t, int64(-1), receipt.GetL1Delta(notSolo.KeyPair.MustGetAddress(requesterKeyPair), colored.IOTA))
+	require.Equal(t, int64(1), receipt.GetL1Delta(chain.ChainID.AsAddress(), colored.IOTA))
+	require.Equal(t, int64(1), receipt.GetAccountDelta(&requesterAgentID, colored.IOTA))
+	require.Equal(t, colored.IOTA, receipt.FeeColor)
+	require.Zero(t, receipt.NominalOwnerFee)
+	require.Zero(t, receipt.NominalValidatorFee)
+	require.Greater(t, int64(receipt.Duration), int64(0))
+
+	// Post a request with a transfer through the request builder
+	transferAmount := uint64(100)
+	receipt = notSolo.Request.NewRequest(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name).
+		WithTransfer(colored.IOTA, transferAmount).MustPostWithReceipt()
+	require.Equal(t, -int64(transferAmount), receipt.GetL1Delta(notSolo.KeyPair.MustGetAddress(requesterKeyPair), colored.IOTA))
+	require.Equal(t, int64(transferAmount), receipt.GetAccountDelta(&requesterAgentID, colored.IOTA))
+}
+
+func Test_PostWithReceipt_Fees(t *testing.T) {
+	notSolo := notsolo.New(t)
+	const ownerFee, validatorFee, transferAmount = uint64(10), uint64(5), uint64(100)
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	requesterAgentID := notSolo.KeyPair.MustGetAgentID(requesterKeyPair)
+
+	// By default, the chain owner receives the validator fees, so its account is charged both fees
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	notSolo.Chain.ChangeContractFees(nil, chain, accounts.Contract.Name, ownerFee)
+	notSolo.Chain.ChangeValidatorFees(nil, chain, accounts.Contract.Name, validatorFee)
+	receipt := notSolo.Request.NewRequest(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name).
+		WithTransfer(colored.IOTA, transferAmount).MustPostWithReceipt()
+	require.Equal(t, colored.IOTA, receipt.FeeColor)
+	require.Equal(t, ownerFee, receipt.NominalOwnerFee)
+	require.Equal(t, validatorFee, receipt.NominalValidatorFee)
+	require.Equal(t, int64(ownerFee+validatorFee), receipt.GetAccountDelta(&chain.OriginatorAgentID, colored.IOTA))
+	require.Equal(t, int64(transferAmount-ownerFee-validatorFee), receipt.GetAccountDelta(&requesterAgentID, colored.IOTA))
+
+	// In a chain whose validator fees go to another agent, each account is charged its fee
+	validatorAgentID := notSolo.KeyPair.MustGetAgentID(notSolo.KeyPair.NewKeyPair())
+	otherChain := notSolo.Chain.NewChain(nil, "myOtherChain", &validatorAgentID)
+	notSolo.Chain.ChangeContractFees(nil, otherChain, accounts.Contract.Name, ownerFee)
+	notSolo.Chain.ChangeValidatorFees(nil, otherChain, accounts.Contract.Name, validatorFee)
+	receipt = notSolo.Request.NewRequest(requesterKeyPair, otherChain, accounts.Contract.Name, accounts.FuncDeposit.Name).
+		WithTransfer(colored.IOTA, transferAmount).MustPostWithReceipt()
+	require.Equal(t, ownerFee, receipt.NominalOwnerFee)
+	require.Equal(t, validatorFee, receipt.NominalValidatorFee)
+	require.Equal(t, int64(ownerFee), receipt.GetAccountDelta(&otherChain.OriginatorAgentID, colored.IOTA))
+	require.Equal(t, int64(validatorFee), receipt.GetAccountDelta(&validatorAgentID, colored.IOTA))
+	require.Equal(t, int64(transferAmount-ownerFee-validatorFee), receipt.GetAccountDelta(&requesterAgentID, colored.IOTA))
+}
+
+func Test_MustPostFailWith(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and key pairs with and without dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	poorKeyPair := notSolo.KeyPair.NewKeyPair()
+
+	// An unknown contract is reported as not found
+	notSolo.Request.MustPostFailWith(requesterKeyPair, chain, "nonExistingContract", accounts.FuncDeposit.Name, requestmanager.ErrNotFound)
+
+	// A misspelled function is reported as not found, not as unauthorized
+	_, err := notSolo.Request.Post(requesterKeyPair, chain, accounts.Contract.Name, "depositt")
+	require.ErrorIs(t, err, requestmanager.ErrNotFound)
+	require.NotErrorIs(t, err, requestmanager.ErrUnauthorized)
+
+	// Changing fees of a contract requires the chain owner
+	notSolo.Request.MustPostFailWith(requesterKeyPair, chain, governance.Contract.Name, governance.FuncSetContractFee.Name, requestmanager.ErrUnauthorized,
+		governance.ParamHname, accounts.Contract.Hname(), governance.ParamOwnerFee, uint64(1))
+
+	// A requester without tokens in L1 cannot attach them
+	_, err = notSolo.Request.Post(poorKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name)
+	require.ErrorIs(t, err, requestmanager.ErrContractInsufficientFunds)
+	require.ErrorIs(t, err, l1ledger.ErrInsufficientFunds)
+	notSolo.Request.MustPostWithTransferFailWith(poorKeyPair, colored.IOTA, 100, chain, accounts.Contract.Name, accounts.FuncDeposit.Name,
+		requestmanager.ErrContractInsufficientFunds)
+
+	// A parameter which cannot be decoded is reported as invalid, with or without transfer
+	notSolo.Request.MustPostFailWith(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name, requestmanager.ErrInvalidParams,
+		accounts.ParamAgentID, "notAnAgentID")
+	notSolo.Request.MustPostWithTransfersFailWith(requesterKeyPair, colored.Balances{colored.IOTA: 100}, chain, accounts.Contract.Name,
+		accounts.FuncDeposit.Name, requestmanager.ErrInvalidParams, accounts.ParamAgentID, "notAnAgentID")
+
+	// Messages of contracts which mention "not found" are not mistaken for unknown contracts or functions
+	_, err = notSolo.Request.Post(nil, chain, root.Contract.Name, root.FuncDeployContract.Name,
+		root.ParamProgramHash, hashing.HashStrings("missing program"), root.ParamName, "myContract")
+	require.ErrorIs(t, err, requestmanager.ErrPanic)
+	require.NotErrorIs(t, err, requestmanager.ErrNotFound)
+}
+
+func Test_MustViewFailWith(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with funds in it
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	requesterAgentID := notSolo.KeyPair.MustGetAgentID(requesterKeyPair)
+	notSolo.L1.MustTransferToChainToSelf(requesterKeyPair, chain, colored.IOTA, 100)
+
+	// Unknown contracts and views are reported as not found
+	notSolo.Request.MustViewFailWith(chain, "nonExistingContract", accounts.FuncViewBalance.Name, requestmanager.ErrNotFound)
+	notSolo.Request.MustViewFailWith(chain, accounts.Contract.Name, "nonExistingView", requestmanager.ErrNotFound)
+
+	// A parameter which cannot be decoded is reported as invalid
+	notSolo.Request.MustViewFailWith(chain, accounts.Contract.Name, accounts.FuncViewBalance.Name, requestmanager.ErrInvalidParams,
+		accounts.ParamAgentID, "notAnAgentID")
+
+	// MustView and MustViewFail go through the same checks
+	notSolo.Request.MustViewFail(chain, "nonExistingContract", accounts.FuncViewBalance.Name)
+	balances := notSolo.Request.MustView(chain, accounts.Contract.Name, accounts.FuncViewBalance.Name, accounts.ParamAgentID, &requesterAgentID)
+	require.NotEmpty(t, balances)
+}
+
+func Test_MustPostFailWithParams(t *testing.T) {
+	notSolo := notsolo.New(t)
+
+	// Create a chain and a key pair with dummy funds (amount is defined in utxodb.RequestFundsAmount)
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	requesterKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+
+	// A deposit to an invalid AgentID fails because of the parameter, with or without transfer, on-ledger or off-ledger
+	notSolo.Request.MustPostFail(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, "notAnAgentID")
+	notSolo.Request.MustPostOffLedgerFail(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, "notAnAgentID")
+	notSolo.Request.NewRequest(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, "notAnAgentID").
+		WithTransfer(colored.IOTA, 100).
+		MustFail()
+
+	// The same request succeeds with a valid parameter
+	requesterAgentID := notSolo.KeyPair.MustGetAgentID(requesterKeyPair)
+	notSolo.Request.NewRequest(requesterKeyPair, chain, accounts.Contract.Name, accounts.FuncDeposit.Name, accounts.ParamAgentID, &requesterAgentID).
+		WithTransfer(colored.IOTA, 100).
+		MustPost()
+}
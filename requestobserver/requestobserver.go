@@ -0,0 +1,81 @@
+package requestobserver
+
+import (
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/kv/dict"
+	"github.com/iotaledger/wasp/packages/solo"
+)
+
+// RequestObserver is notified before and after a request is posted to a chain. 'chain' is nil for transfers in L1.
+// 'addresses' are the L1 addresses of the requester and of the receivers named by the request, which may receive tokens from it.
+type RequestObserver interface {
+	BeforeRequest(chain *solo.Chain, addresses []ledgerstate.Address)
+	AfterRequest(chain *solo.Chain)
+}
+
+// Observers is a list of observers which are notified of every request posted through it
+type Observers []RequestObserver
+
+// Add appends 'observer' to the list
+func (observers *Observers) Add(observer RequestObserver) {
+	*observers = append(*observers, observer)
+}
+
+// PostRequestSync posts 'request' to 'chain', as solo.Chain.PostRequestSync does, and notifies the observers before and after it
+func (observers Observers) PostRequestSync(chain *solo.Chain, request *solo.CallParams, keyPair *ed25519.KeyPair) (dict.Dict, error) {
+	return observers.notify(chain, requesterAddresses(chain, keyPair), func() (dict.Dict, error) {
+		return chain.PostRequestSync(request, keyPair)
+	})
+}
+
+// PostRequestSyncTx posts 'request' to 'chain', as solo.Chain.PostRequestSyncTx does, and notifies the observers before and after it
+func (observers Observers) PostRequestSyncTx(chain *solo.Chain, request *solo.CallParams, keyPair *ed25519.KeyPair) (*ledgerstate.Transaction, dict.Dict, error) {
+	var transaction *ledgerstate.Transaction
+	response, err := observers.notify(chain, requesterAddresses(chain, keyPair), func() (dict.Dict, error) {
+		var response dict.Dict
+		var err error
+		transaction, response, err = chain.PostRequestSyncTx(request, keyPair)
+		return response, err
+	})
+	return transaction, response, err
+}
+
+// PostRequestOffLedger posts 'request' to 'chain', as solo.Chain.PostRequestOffLedger does, and notifies the observers before and after it
+func (observers Observers) PostRequestOffLedger(chain *solo.Chain, request *solo.CallParams, keyPair *ed25519.KeyPair) (dict.Dict, error) {
+	return observers.notify(chain, requesterAddresses(chain, keyPair), func() (dict.Dict, error) {
+		return chain.PostRequestOffLedger(request, keyPair)
+	})
+}
+
+// Notify calls 'action', which posts requests to 'chain' through solo, waits for them to be processed or, if 'chain' is nil, transfers
+// tokens in L1, and notifies the observers before and after it. 'addresses' are the L1 addresses of the requesters and receivers of 'action'.
+// Returns the error of 'action'.
+func (observers Observers) Notify(chain *solo.Chain, addresses []ledgerstate.Address, action func() error) error {
+	_, err := observers.notify(chain, addresses, func() (dict.Dict, error) {
+		return nil, action()
+	})
+	return err
+}
+
+// notify calls 'post' and notifies the observers before and after it
+func (observers Observers) notify(chain *solo.Chain, addresses []ledgerstate.Address, post func() (dict.Dict, error)) (dict.Dict, error) {
+	for _, observer := range observers {
+		observer.BeforeRequest(chain, addresses)
+	}
+
+	response, err := post()
+
+	for _, observer := range observers {
+		observer.AfterRequest(chain)
+	}
+	return response, err
+}
+
+// requesterAddresses returns the L1 address of 'keyPair' or, if not specified, of the originator of 'chain', which solo signs with instead
+func requesterAddresses(chain *solo.Chain, keyPair *ed25519.KeyPair) []ledgerstate.Address {
+	if keyPair == nil {
+		keyPair = chain.OriginatorKeyPair
+	}
+	return []ledgerstate.Address{ledgerstate.NewED25519Address(keyPair.PublicKey)}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"testing"
+
+	notsolo "github.com/brunoamancio/NotSolo"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate"
+	"github.com/iotaledger/goshimmer/packages/ledgerstate/utxodb"
+	"github.com/iotaledger/hive.go/crypto/ed25519"
+	"github.com/iotaledger/wasp/packages/iscp"
+	"github.com/iotaledger/wasp/packages/iscp/colored"
+	"github.com/iotaledger/wasp/packages/vm/core/accounts"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_New_ReturnsIsolatedInstances(t *testing.T) {
+	// Arrange
+	notSolo := notsolo.New(t)
+
+	// Act
+	otherNotSolo := notsolo.New(t)
+
+	// Assert
+	require.NotSame(t, notSolo, otherNotSolo)
+	require.NotSame(t, notSolo.Chain, otherNotSolo.Chain)
+	require.NotSame(t, notSolo.KeyPair, otherNotSolo.KeyPair)
+}
+
+func Test_New_Parallel(t *testing.T) {
+	for _, testName := range []string{"first", "second", "third"} {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			notSolo := notsolo.New(t)
+
+			// Create a chain with the same name as the other tests
+			chain := notSolo.Chain.NewChain(nil, "myChain")
+
+			// Send some funds to chain
+			senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+			transferAmount := uint64(100)
+			notSolo.L1.MustTransferToChainToSelf(senderKeyPair, chain, colored.IOTA, transferAmount)
+			notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, utxodb.RequestFundsAmount-transferAmount)
+			notSolo.Chain.RequireBalance(senderKeyPair, chain, colored.IOTA, transferAmount)
+		})
+	}
+}
+
+func Test_NewPooled_Parallel(t *testing.T) {
+	for _, testName := range []string{"first", "second", "third"} {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			notSolo := notsolo.NewPooled(t)
+
+			// Key pairs are new in every test, even if the solo environment is reused
+			senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+			notSolo.L1.RequireBalance(senderKeyPair, colored.IOTA, utxodb.RequestFundsAmount)
+		})
+	}
+}
+
+func Test_New_WithOptions(t *testing.T) {
+	notSolo := notsolo.New(t, notsolo.WithDebug(), notsolo.WithStackTrace(), notsolo.WithSeed(ed25519.NewSeed()))
+
+	keyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	notSolo.L1.RequireBalance(keyPair, colored.IOTA, utxodb.RequestFundsAmount)
+}
+
+func Test_New_WithEnvironmentVariables(t *testing.T) {
+	t.Setenv(notsolo.EnvDebug, "true")
+	t.Setenv(notsolo.EnvStackTrace, "true")
+	t.Setenv(notsolo.EnvSeed, ed25519.NewSeed().String())
+
+	notSolo := notsolo.New(t)
+
+	keyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	notSolo.L1.RequireBalance(keyPair, colored.IOTA, utxodb.RequestFundsAmount)
+}
+
+func Test_New_WithSeed_IsReproducible(t *testing.T) {
+	// Arrange
+	seed := ed25519.NewSeed()
+	notSolo := notsolo.New(t, notsolo.WithSeed(seed))
+	otherNotSolo := notsolo.New(t, notsolo.WithSeed(seed))
+
+	// Act
+	keyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	otherKeyPair := otherNotSolo.KeyPair.NewKeyPairWithFunds()
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	otherChain := otherNotSolo.Chain.NewChain(nil, "myChain")
+
+	// Assert
+	require.Equal(t, seed.Bytes(), notSolo.GetSeed().Bytes())
+	require.Equal(t, notSolo.KeyPair.MustGetAddress(keyPair), otherNotSolo.KeyPair.MustGetAddress(otherKeyPair))
+	// Chain originators are derived from the seed too. Chain IDs are not, since they depend on the time solo created its ledger.
+	require.Equal(t, notSolo.KeyPair.MustGetAddress(chain.OriginatorKeyPair), otherNotSolo.KeyPair.MustGetAddress(otherChain.OriginatorKeyPair))
+}
+
+func Test_New_WithConservationCheck(t *testing.T) {
+	notSolo := notsolo.New(t, notsolo.WithConservationCheck())
+
+	// Tokens only move between L1 and chains
+	chain := notSolo.Chain.NewChain(nil, "myChain")
+	senderKeyPair := notSolo.KeyPair.NewKeyPairWithFunds()
+	receiverKeyPair := notSolo.KeyPair.NewKeyPair()
+	notSolo.L1.MustTransferToChainToSelf(senderKeyPair, chain, colored.IOTA, 100)
+	notSolo.Chain.MustTransferWithinChain(senderKeyPair, chain, colored.IOTA, 100, receiverKeyPair)
+
+	// Tokens leave the known addresses to an address which is not generated by the key pair manager
+	externalKeyPair := ed25519.GenerateKeyPair()
+	externalAddress := ledgerstate.NewED25519Address(externalKeyPair.PublicKey)
+	notSolo.Chain.MustTransferToAgentWithinChain(receiverKeyPair, chain, colored.IOTA, 50, iscp.NewAgentID(externalAddress, 0))
+	notSolo.Request.MustPostOffLedger(&externalKeyPair, chain, accounts.Contract.Name, accounts.FuncWithdraw.Name)
+	notSolo.L1.MustTransferL1(senderKeyPair, externalAddress, colored.IOTA, 10)
+	notSolo.L1.RequireAddressBalance(externalAddress, colored.IOTA, 60)
+}